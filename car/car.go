@@ -3,17 +3,48 @@ package car
 import (
 	"errors"
 	"fmt"
+	"sync"
 
 	"sdmimaye.de/smart-video-car/components"
+	"sdmimaye.de/smart-video-car/hardware"
+	"sdmimaye.de/smart-video-car/network"
 	"sdmimaye.de/smart-video-car/steering"
 	"sdmimaye.de/smart-video-car/stream"
 )
 
-//Car represents our smart-video-car
+//Endpoints contains the listen addresses (see network.ResolveAddress) of the network steering engines. Every UDP address
+//starts its own UDP engine
+type Endpoints struct {
+	UDP       []string
+	WebSocket string
+	REST      string
+	Console   string
+}
+
+//DefaultEndpoints are the listen addresses used if nothing else is configured
+var DefaultEndpoints = Endpoints{
+	UDP:       []string{steering.DefaultUDPAddress},
+	WebSocket: steering.DefaultWebSocketAddress,
+	REST:      DefaultRESTAddress,
+}
+
+//Car represents our smart-video-car. Validation determines how steps with values out of range are handled, Endpoints
+//where the network steering engines listen. Name is used to announce the car on the local network
 type Car struct {
-	Motor    *components.CalibratedMotor
-	Steering *components.CalibratedSteering
-	Camera   *components.CalibratedCamera
+	Name       string
+	Motor      *components.CalibratedMotor
+	Steering   *components.CalibratedSteering
+	Camera     *components.CalibratedCamera
+	Validation steering.ValidationMode
+	Endpoints  Endpoints
+
+	//actuation serializes every actuator write (Move, SetCalibration and the interactive calibration), so that no step
+	//is applied with a half written calibration and no hardware batch of Move collects writes of another goroutine
+	actuation sync.Mutex
+	mutex     sync.Mutex
+	last      steering.Step
+	failed    bool
+	recorder  *steering.Recorder
 }
 
 //NewCar will create a new smart car instance
@@ -32,7 +63,135 @@ func NewCar() (*Car, error) {
 		return nil, errors.New("Could not create calibrated Camera for car. Error: " + err.Error())
 	}
 
-	return &Car{Motor: motor, Camera: camera, Steering: steering}, nil
+	return &Car{Motor: motor, Camera: camera, Steering: steering, Endpoints: DefaultEndpoints}, nil
+}
+
+//Calibration represents the calibration of all car components
+type Calibration struct {
+	Motor    components.MotorCalibration    `json:"motor"`
+	Steering components.SteeringCalibration `json:"steering"`
+	Camera   components.CameraCalibration   `json:"camera"`
+}
+
+//Calibration will return the current calibration of all car components
+func (c *Car) Calibration() Calibration {
+	return Calibration{Motor: c.Motor.Calibration(), Steering: c.Steering.Calibration(), Camera: c.Camera.Calibration()}
+}
+
+//SetCalibration will store a new calibration for all car components. It waits until a running Move finished, so that
+//no step is applied with a half written calibration. All calibrations are validated before any of them is applied
+func (c *Car) SetCalibration(cal Calibration) error {
+	c.actuation.Lock()
+	defer c.actuation.Unlock()
+
+	err := c.Motor.ValidateCalibration(cal.Motor)
+	if err != nil {
+		return fmt.Errorf("Could not calibrate motor. Error: %v", err)
+	}
+	err = c.Steering.ValidateCalibration(cal.Steering)
+	if err != nil {
+		return fmt.Errorf("Could not calibrate steering. Error: %v", err)
+	}
+	err = c.Camera.ValidateCalibration(cal.Camera)
+	if err != nil {
+		return fmt.Errorf("Could not calibrate camera. Error: %v", err)
+	}
+
+	err = c.Motor.SetCalibration(cal.Motor)
+	if err != nil {
+		return fmt.Errorf("Could not calibrate motor. Error: %v", err)
+	}
+	err = c.Steering.SetCalibration(cal.Steering)
+	if err != nil {
+		return fmt.Errorf("Could not calibrate steering. Error: %v", err)
+	}
+	err = c.Camera.SetCalibration(cal.Camera)
+	if err != nil {
+		return fmt.Errorf("Could not calibrate camera. Error: %v", err)
+	}
+
+	return nil
+}
+
+//StartRecording will record every step received by any engine into a file until StopRecording is called
+func (c *Car) StartRecording(path string) error {
+	recorder, err := steering.NewRecorder(path)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	previous := c.recorder
+	c.recorder = recorder
+	c.mutex.Unlock()
+	if previous != nil {
+		previous.Close()
+	}
+
+	return nil
+}
+
+//StopRecording will stop the current recording
+func (c *Car) StopRecording() error {
+	c.mutex.Lock()
+	recorder := c.recorder
+	c.recorder = nil
+	c.mutex.Unlock()
+	if recorder == nil {
+		return nil
+	}
+
+	return recorder.Close()
+}
+
+//Recording will return the current recorder or nil if the car is not recording
+func (c *Car) Recording() *steering.Recorder {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.recorder
+}
+
+//WriteStats contains the written and avoided hardware writes per actuator
+type WriteStats struct {
+	MotorPWM  components.WriteStats `json:"motorPwm"`
+	MotorPins components.WriteStats `json:"motorPins"`
+	Steering  components.WriteStats `json:"steering"`
+	Camera    components.WriteStats `json:"camera"`
+}
+
+func (s WriteStats) String() string {
+	return fmt.Sprintf("Writes (written/avoided) motor pwm: %v/%v, motor pins: %v/%v, steering: %v/%v, camera: %v/%v",
+		s.MotorPWM.Written, s.MotorPWM.Avoided, s.MotorPins.Written, s.MotorPins.Avoided,
+		s.Steering.Written, s.Steering.Avoided, s.Camera.Written, s.Camera.Avoided)
+}
+
+//WriteStats will return the counters of written and avoided hardware writes of all actuators
+func (c *Car) WriteStats() WriteStats {
+	pwm, pins := c.Motor.WriteStats()
+	return WriteStats{MotorPWM: pwm, MotorPins: pins, Steering: c.Steering.WriteStats(), Camera: c.Camera.WriteStats()}
+}
+
+//SetServoHysteresis will set the number of degrees steering and camera servos have to move before their angle is written
+func (c *Car) SetServoHysteresis(degrees int) {
+	c.Steering.SetHysteresis(degrees)
+	c.Camera.SetHysteresis(degrees)
+}
+
+//Announcement will return the announcement of the car for the LAN discovery beacon
+func (c *Car) Announcement() network.Announcement {
+	a := network.Announcement{
+		Name:       c.Name,
+		WebSocket:  network.Port(c.Endpoints.WebSocket),
+		REST:       network.Port(c.Endpoints.REST),
+		Console:    network.Port(c.Endpoints.Console),
+		Calibrated: c.State().Flags&steering.StatusCalibrated != 0,
+	}
+	for _, address := range c.Endpoints.UDP {
+		a.UDP = append(a.UDP, network.Port(address))
+	}
+
+	return a
 }
 
 //Listen will make the car listen to the incomming requests from the stream and move accordingly
@@ -40,8 +199,80 @@ func (c *Car) Listen(stream stream.Stream) {
 	Execute(c, stream)
 }
 
-//Move will move the car with a certain step
+//Observe will show the state of the car on the stream without accepting any commands
+func (c *Car) Observe(stream stream.Stream) {
+	Observe(c, stream)
+}
+
+//State will return the current state of the car with the last successfully applied step
+func (c *Car) State() steering.State {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	state := steering.State{Step: c.last}
+	if c.Steering.Calibrated() && c.Camera.Calibrated() {
+		state.Flags |= steering.StatusCalibrated
+	}
+	if c.last.Speed != 0 {
+		state.Flags |= steering.StatusMoving
+	}
+	if c.failed {
+		state.Flags |= steering.StatusError
+	}
+
+	return state
+}
+
+//Validate will validate (and in clamp mode correct) a step according to the validation mode of the car
+func (c *Car) Validate(step *steering.Step) error {
+	return step.Validate(c.Validation)
+}
+
+//Move will move the car with a certain step. The whole step is validated before any component is moved. All pwm and
+//servo updates of the step are written together in one batch
 func (c *Car) Move(step *steering.Step) error {
+	validated := *step
+	err := c.Validate(&validated)
+	if err == nil {
+		c.actuation.Lock()
+		batch := hardware.BeginBatch()
+		err = c.doMove(&validated)
+		flushErr := batch.Flush()
+		if flushErr != nil {
+			c.doInvalidate()
+			if err == nil {
+				err = fmt.Errorf("Could not write actuators. Error: %v", flushErr)
+			}
+		}
+		c.actuation.Unlock()
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.failed = err != nil
+	if err == nil {
+		c.last = validated
+	}
+
+	return err
+}
+
+//doActuate will run f while no other goroutine writes to the actuators of the car
+func (c *Car) doActuate(f func() error) error {
+	c.actuation.Lock()
+	defer c.actuation.Unlock()
+
+	return f()
+}
+
+//doInvalidate will forget the last commanded values of all actuators, so that the next step writes everything again
+func (c *Car) doInvalidate() {
+	c.Motor.Invalidate()
+	c.Steering.Invalidate()
+	c.Camera.Invalidate()
+}
+
+func (c *Car) doMove(step *steering.Step) error {
 	err := c.Motor.SetSpeed(step.Speed)
 	if err != nil {
 		return fmt.Errorf("Could not accelerate/decelerate. Error: %v", err)
@@ -2,20 +2,31 @@ package car
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"log"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"sdmimaye.de/smart-video-car/hardware"
+	"sdmimaye.de/smart-video-car/latency"
 	"sdmimaye.de/smart-video-car/steering"
 	"sdmimaye.de/smart-video-car/stream"
 )
 
+const gamepadMappingFilePath = "gamepad.ini"
+
 type calibration struct {
 	motor    bool
 	steering bool
 	camera   bool
 }
 
+//doCalibrate will interactively calibrate the chosen parts of the car. Steps of other sessions wait until a part is
+//calibrated
 func doCalibrate(c *Car, stream stream.Stream) error {
 	r := stream.GetReader()
 	w := stream.GetWriter()
@@ -37,19 +48,19 @@ func doCalibrate(c *Car, stream stream.Stream) error {
 		}
 
 		if cali.motor {
-			err = c.Motor.Calibrate(stream)
+			err = c.doActuate(func() error { return c.Motor.Calibrate(stream) })
 			if err != nil {
 				return err
 			}
 		}
 		if cali.steering {
-			err = c.Steering.Calibrate(stream)
+			err = c.doActuate(func() error { return c.Steering.Calibrate(stream) })
 			if err != nil {
 				return err
 			}
 		}
 		if cali.camera {
-			err = c.Camera.Calibrate(stream)
+			err = c.doActuate(func() error { return c.Camera.Calibrate(stream) })
 			if err != nil {
 				return err
 			}
@@ -60,36 +71,324 @@ func doCalibrate(c *Car, stream stream.Stream) error {
 	}
 }
 
+var engineNames = []string{"UDP", "WebSocket (Browser)", "UDP and HTTP REST API", "MQTT", "Gamepad", "Keyboard", "Mission (Script)", "Replay"}
+
+//engineListeners contains the listeners a steering method opens on the endpoints of the car (see Endpoints)
+var engineListeners = map[int][]string{0: {"UDP"}, 1: {"WebSocket"}, 2: {"UDP", "REST"}}
+
+func doSelectEngine(c *Car, stream stream.Stream, reader *bufio.Reader, command string) (steering.Engine, error) {
+	w := stream.GetWriter()
+
+	if strings.HasPrefix(command, "0") {
+		return doUDPEngines(c), nil
+	} else if strings.HasPrefix(command, "1") {
+		fmt.Fprintf(w, "Open http://%v/ in your browser to steer\r\n", doDisplayAddress(c.Endpoints.WebSocket))
+		return &steering.WebSocketEngine{Address: c.Endpoints.WebSocket, State: c.State}, nil
+	} else if strings.HasPrefix(command, "2") {
+		fmt.Fprintf(w, "REST API is available on http://%v/api (OpenAPI: /api/openapi.json)\r\n", doDisplayAddress(c.Endpoints.REST))
+		engines := doUDPEngines(c)
+		engines.Engines = append(engines.Engines, NewRESTEngine(c, c.Endpoints.REST, doIdentity(stream)))
+		return engines, nil
+	} else if strings.HasPrefix(command, "3") {
+		fmt.Fprint(w, "Please enter the MQTT broker (empty for tcp://localhost:1883):\r\n")
+		broker, _ := reader.ReadString('\n')
+		broker = strings.TrimSpace(broker)
+		if broker == "" {
+			broker = "tcp://localhost:1883"
+		}
+		fmt.Fprint(w, "Please enter the MQTT topic of the car (empty for smart-video-car):\r\n")
+		topic, _ := reader.ReadString('\n')
+		topic = strings.TrimSpace(topic)
+		if topic == "" {
+			topic = "smart-video-car"
+		}
+		return &steering.MQTTEngine{Broker: broker, ClientID: topic, Topic: topic, Failsafe: time.Second, State: c.State}, nil
+	} else if strings.HasPrefix(command, "4") {
+		fmt.Fprint(w, "Please enter the gamepad device (empty for /dev/input/js0):\r\n")
+		device, _ := reader.ReadString('\n')
+		device = strings.TrimSpace(device)
+		if device == "" {
+			device = "/dev/input/js0"
+		}
+		gamepad := steering.NewGamepadEngine(device)
+		mapping, err := steering.LoadGamepadMapping(gamepadMappingFilePath, "Gamepad", gamepad.Mapping)
+		if err != nil {
+			log.Printf("Using default gamepad mapping. Reason: %v\n", err)
+		}
+		gamepad.Mapping = mapping
+		return gamepad, nil
+	} else if strings.HasPrefix(command, "5") {
+		return &steering.KeyboardEngine{Stream: stream, State: c.State}, nil
+	} else if strings.HasPrefix(command, "6") {
+		fmt.Fprint(w, "Please enter the path of the mission script:\r\n")
+		path, _ := reader.ReadString('\n')
+		mission, err := steering.LoadMission(strings.TrimSpace(path))
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprint(w, "Dry-run (print the timeline without moving)? [y/N]\r\n")
+		dry, _ := reader.ReadString('\n')
+		return &steering.MissionEngine{Stream: stream, Mission: mission, DryRun: strings.HasPrefix(strings.ToLower(dry), "y")}, nil
+	} else if strings.HasPrefix(command, "7") {
+		return doReadReplay(reader, w)
+	} else if strings.HasPrefix(command, "8") {
+		return doSelectArbiter(c, stream, reader)
+	}
+
+	return nil, fmt.Errorf("Unknown steering method: %v\r\n", command)
+}
+
+//doUDPEngines will create one UDP engine per configured UDP address
+func doUDPEngines(c *Car) *steering.Engines {
+	addresses := c.Endpoints.UDP
+	if len(addresses) == 0 {
+		addresses = []string{steering.DefaultUDPAddress}
+	}
+
+	engines := steering.NewEngines()
+	for _, address := range addresses {
+		engines.Engines = append(engines.Engines, &steering.UDPEngine{Address: address, AckEvery: 1, State: c.State})
+	}
+
+	return engines
+}
+
+//doDisplayAddress will replace an empty host of a listen address with a placeholder for the car
+func doDisplayAddress(address string) string {
+	if strings.HasPrefix(address, ":") {
+		return "<car>" + address
+	}
+
+	return address
+}
+
+func doSelectArbiter(c *Car, stream stream.Stream, reader *bufio.Reader) (steering.Engine, error) {
+	w := stream.GetWriter()
+	fmt.Fprint(w, "Please enter the steering methods separated by comma, highest priority first (e.g. 4,0):\r\n")
+	list, _ := reader.ReadString('\n')
+	fmt.Fprint(w, "Please enter the timeout after which a steering method loses control (empty for 500ms):\r\n")
+	value, _ := reader.ReadString('\n')
+	timeout := 500 * time.Millisecond
+	if value = strings.TrimSpace(value); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid timeout: %v", value)
+		}
+		timeout = parsed
+	}
+
+	arbiter := steering.Arbiter{OnHandover: func(from string, to string) {
+		fmt.Fprintf(w, "Steering handed over from: %v to: %v\r\n", from, to)
+	}}
+	commands := strings.Split(strings.TrimSpace(list), ",")
+	indices, err := doArbitratedEngines(commands)
+	if err != nil {
+		return nil, err
+	}
+	for i, index := range indices {
+		engine, err := doSelectEngine(c, stream, reader, strconv.Itoa(index))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := engine.(steering.InteractiveEngine); ok {
+			return nil, fmt.Errorf("Steering method %v takes over the console and can not be arbitrated", engineNames[index])
+		}
+		arbiter.Sources = append(arbiter.Sources, steering.ArbiterSource{Name: engineNames[index], Engine: engine, Priority: len(indices) - i, Timeout: timeout})
+	}
+	if len(arbiter.Sources) == 0 {
+		return nil, fmt.Errorf("No steering method selected")
+	}
+
+	return &arbiter, nil
+}
+
+//doArbitratedEngines will return the indices of the steering methods before any of them asks for its settings. A method
+//can only be selected once and no two methods may listen on the same endpoints
+func doArbitratedEngines(commands []string) ([]int, error) {
+	var indices []int
+	selected := make(map[int]bool)
+	listeners := make(map[string]string)
+	for _, command := range commands {
+		command = strings.TrimSpace(command)
+		index, err := strconv.Atoi(command)
+		if err != nil || index < 0 || index >= len(engineNames) {
+			return nil, fmt.Errorf("Invalid steering method for arbitration: %v", command)
+		}
+		if selected[index] {
+			return nil, fmt.Errorf("Steering method %v was selected twice", engineNames[index])
+		}
+		selected[index] = true
+		for _, listener := range engineListeners[index] {
+			if other, ok := listeners[listener]; ok {
+				return nil, fmt.Errorf("Steering methods %v and %v would both listen on the %v endpoints", other, engineNames[index], listener)
+			}
+			listeners[listener] = engineNames[index]
+		}
+		indices = append(indices, index)
+	}
+
+	return indices, nil
+}
+
 func doSteer(c *Car, stream stream.Stream) error {
 	r := stream.GetReader()
 	w := stream.GetWriter()
 	reader := bufio.NewReader(r)
 
-	var s steering.Engine
-	fmt.Fprint(w, "Please select your steering method:\r\n[0] UDP\r\n")
+	fmt.Fprint(w, "Please select your steering method:\r\n")
+	for i, name := range engineNames {
+		fmt.Fprintf(w, "[%v] %v\r\n", i, name)
+	}
+	fmt.Fprintf(w, "[%v] Several steering methods with priority\r\n", len(engineNames))
 	command, _ := reader.ReadString('\n')
-	if strings.HasPrefix(command, "0") {
-		s = steering.UDPEngine{}
-	} else {
-		return fmt.Errorf("Unknown steering method: %v\r\n", command)
+	s, err := doSelectEngine(c, stream, reader, command)
+	if err != nil {
+		return err
 	}
 
-	err := s.StartEngine(func(step *steering.Step) {
+	//failed steps are counted by the mailbox and rejected ones here, they are only logged one by one in verbose mode
+	mailbox := steering.NewMailbox(func(step *steering.Step) error {
 		err := c.Move(step)
-		if err != nil {
+		if err != nil && hardware.Verbose {
 			log.Printf("Could not steer car. Error: %v", err)
 		}
+		return err
+	})
+	var rejected atomic.Uint64
+	defer func() {
+		mailbox.Close()
+		log.Printf("Steering finished. Rejected steps: %v, Mailbox: %v\n", rejected.Load(), mailbox.Stats())
+	}()
+
+	err = s.Start(context.Background(), func(step *steering.Step) error {
+		if recorder := c.Recording(); recorder != nil {
+			recorder.Record(step)
+		}
+		err := c.Validate(step)
+		if err != nil {
+			rejected.Add(1)
+			if hardware.Verbose {
+				log.Printf("Rejected step. Error: %v", err)
+			}
+			return err
+		}
+		return mailbox.Put(step)
 	})
 	if err != nil {
 		return fmt.Errorf("Could not start steering method: %v. Error: %v", command, err)
 	}
-	defer s.EndEngine()
+	go func(errors <-chan error) {
+		for err := range errors {
+			log.Printf("Steering method: %v. Error: %v\n", strings.TrimSpace(command), err)
+		}
+	}(s.Errors())
+	if _, ok := s.(steering.InteractiveEngine); ok {
+		return s.Wait()
+	}
+
+	go func() {
+		err := s.Wait()
+		if err != nil {
+			fmt.Fprintf(w, "Steering method failed. Error: %v\r\nPress any key to exit steering\r\n", err)
+		}
+	}()
+	if arbiter, ok := s.(*steering.Arbiter); ok {
+		fmt.Fprint(w, "Press [s] to show the steering method in control, any other key to exit steering\r\n")
+		for line, _ := reader.ReadString('\n'); strings.TrimSpace(line) == "s"; line, _ = reader.ReadString('\n') {
+			fmt.Fprintf(w, "Steering method in control: %v\r\n", arbiter.Current())
+		}
+		return s.Stop()
+	}
 	fmt.Fprint(w, "Press any key to exit steering\r\n")
 	reader.ReadString('\n')
+	return s.Stop()
+}
+
+func doReadReplay(reader *bufio.Reader, w io.Writer) (*steering.ReplayEngine, error) {
+	replay := steering.ReplayEngine{Scale: 1}
+	fmt.Fprint(w, "Please enter the path of the recording:\r\n")
+	path, _ := reader.ReadString('\n')
+	replay.Path = strings.TrimSpace(path)
+
+	fmt.Fprint(w, "Please enter the time scale (empty for 1, 2 is twice as fast):\r\n")
+	scale, _ := reader.ReadString('\n')
+	if scale = strings.TrimSpace(scale); scale != "" {
+		value, err := strconv.ParseFloat(scale, 64)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("Invalid time scale: %v", scale)
+		}
+		replay.Scale = value
+	}
+
+	fmt.Fprint(w, "Please enter the time window, e.g. 5s-20s (empty for the whole recording):\r\n")
+	window, _ := reader.ReadString('\n')
+	if window = strings.TrimSpace(window); window != "" {
+		bounds := strings.SplitN(window, "-", 2)
+		from, err := time.ParseDuration(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid start of time window: %v", bounds[0])
+		}
+		replay.From = from
+		if len(bounds) == 2 && bounds[1] != "" {
+			to, err := time.ParseDuration(bounds[1])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid end of time window: %v", bounds[1])
+			}
+			replay.To = to
+		}
+	}
+
+	return &replay, nil
+}
+
+func doRecord(c *Car, stream stream.Stream) error {
+	w := stream.GetWriter()
+	if recorder := c.Recording(); recorder != nil {
+		fmt.Fprintf(w, "Stopped recording to: %v\r\n", recorder.Path)
+		return c.StopRecording()
+	}
+
+	reader := bufio.NewReader(stream.GetReader())
+	path := fmt.Sprintf("steps-%v.jsonl", time.Now().Format("20060102-150405"))
+	fmt.Fprintf(w, "Please enter the path of the recording (empty for %v):\r\n", path)
+	value, _ := reader.ReadString('\n')
+	if value = strings.TrimSpace(value); value != "" {
+		path = value
+	}
+
+	err := c.StartRecording(path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Recording all steps to: %v\r\n", path)
+	return nil
+}
+
+//commandPermissions are the permissions required for the commands of the main menu
+var commandPermissions = map[string]stream.Permission{
+	"0": stream.PermissionCalibrate,
+	"1": stream.PermissionSteer,
+	"2": stream.PermissionRecord,
+}
+
+//doIdentity will return the identity of the user of a stream
+func doIdentity(s stream.Stream) stream.Identity {
+	return stream.GetIdentity(s)
+}
+
+//doAuthorize will return an error if the user of the stream is not allowed to execute a command of the main menu
+func doAuthorize(s stream.Stream, command string) error {
+	identity := stream.GetIdentity(s)
+	for prefix, permission := range commandPermissions {
+		if strings.HasPrefix(command, prefix) && !identity.Allowed(permission) {
+			return fmt.Errorf("User: %v is not allowed to execute command: %v", identity.User, strings.TrimSpace(command))
+		}
+	}
+
 	return nil
 }
 
-//Execute will execute all car related commands
+//Execute will execute all car related commands. Commands the user of the stream is not allowed to execute are rejected
 func Execute(c *Car, stream stream.Stream) {
 	stream.OnConnectionEstablished(func() {
 		log.Println("(Re-)Starting Car-Execution")
@@ -97,11 +396,14 @@ func Execute(c *Car, stream stream.Stream) {
 		w := stream.GetWriter()
 
 		for {
-			fmt.Fprint(w, "Please enter your next command:\r\n[0] Calibrate\r\n[1] Steer\r\n")
+			fmt.Fprint(w, "Please enter your next command:\r\n[0] Calibrate\r\n[1] Steer\r\n[2] Start/Stop recording\r\n[3] Latency and write status\r\n")
 			reader := bufio.NewReader(r)
 
 			command, _ := reader.ReadString('\n')
-			if strings.HasPrefix(command, "0") {
+			if err := doAuthorize(stream, command); err != nil {
+				log.Println(err)
+				fmt.Fprintf(w, "%v\r\n", err)
+			} else if strings.HasPrefix(command, "0") {
 				err := doCalibrate(c, stream)
 				if err != nil {
 					fmt.Fprintf(w, "Error while calibrating car. Error: %v\r\n", err)
@@ -111,9 +413,48 @@ func Execute(c *Car, stream stream.Stream) {
 				if err != nil {
 					fmt.Fprintf(w, "Error while steering car. Error: %v\r\n", err)
 				}
+			} else if strings.HasPrefix(command, "2") {
+				err := doRecord(c, stream)
+				if err != nil {
+					fmt.Fprintf(w, "Error while recording. Error: %v\r\n", err)
+				}
+			} else if strings.HasPrefix(command, "3") {
+				fmt.Fprintf(w, "%v\r\n%v\r\n", strings.ReplaceAll(latency.Report(), "\n", "\r\n"), c.WriteStats())
 			} else {
 				return
 			}
 		}
 	})
 }
+
+//Observe will print the state of the car once per second until the client presses enter or disconnects. It is used for
+//clients which are not allowed to control the car
+func Observe(c *Car, stream stream.Stream) {
+	w := stream.GetWriter()
+	fmt.Fprint(w, "Another client controls the car. You can only observe it. Press enter to leave\r\n")
+
+	done := make(chan struct{})
+	go func() {
+		bufio.NewReader(stream.GetReader()).ReadString('\n')
+		close(done)
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		state := c.State()
+		_, err := fmt.Fprintf(w, "\rSpeed: %v, Steering: %v %v%%, Camera: %v %v%% / %v %v%%, Flags: %v\x1b[K", state.Step.Speed,
+			state.Step.CarMovement, state.Step.CarMovementPercentage, state.Step.CameraHMovement, state.Step.CameraHPercentage,
+			state.Step.CameraVMovement, state.Step.CameraVPercentage, state.Flags)
+		if err != nil {
+			return
+		}
+
+		select {
+		case <-done:
+			fmt.Fprint(w, "\r\n")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package car
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//TestArbitratedEngines will check that no steering method is selected twice and no endpoint is listened on twice
+func TestArbitratedEngines(t *testing.T) {
+	tests := []struct {
+		list    string
+		indices []int
+		err     string
+	}{
+		{"4, 0", []int{4, 0}, ""},
+		{"2,1,3", []int{2, 1, 3}, ""},
+		{"0,2", nil, "both listen on the UDP endpoints"},
+		{"2,0", nil, "both listen on the UDP endpoints"},
+		{"1,1", nil, "selected twice"},
+		{"4,x", nil, "Invalid steering method"},
+		{"8", nil, "Invalid steering method"},
+	}
+
+	for _, test := range tests {
+		indices, err := doArbitratedEngines(strings.Split(test.list, ","))
+		if test.err == "" && err != nil {
+			t.Errorf("%v: returned: %v", test.list, err)
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%v: returned: %v, expected an error containing: %v", test.list, err, test.err)
+		}
+		if !reflect.DeepEqual(indices, test.indices) {
+			t.Errorf("%v: returned: %v, expected: %v", test.list, indices, test.indices)
+		}
+	}
+}
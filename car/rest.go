@@ -0,0 +1,268 @@
+package car
+
+import (
+	"context"
+	_ "embed" //Required to embed the OpenAPI description
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"sdmimaye.de/smart-video-car/latency"
+	"sdmimaye.de/smart-video-car/network"
+	"sdmimaye.de/smart-video-car/steering"
+	"sdmimaye.de/smart-video-car/stream"
+)
+
+//go:embed rest-openapi.json
+var openAPI []byte
+
+//DefaultRESTAddress is the address the REST engine will listen on if no address is configured
+const DefaultRESTAddress = ":8081"
+
+//RESTEngine will expose the car over a HTTP REST API with JSON bodies. The OpenAPI description is served on /api/openapi.json.
+//The API acts on behalf of Identity, the car can only be moved if it is allowed to steer and the calibration can only be
+//written if it is allowed to calibrate the car. Movements are queued and applied after the response was written
+type RESTEngine struct {
+	steering.Lifecycle
+	Address  string
+	Identity stream.Identity
+	car      *Car
+}
+
+type restStatus struct {
+	State      steering.State `json:"state"`
+	Calibrated bool           `json:"calibrated"`
+	Moving     bool           `json:"moving"`
+	Failed     bool           `json:"failed"`
+	Writes     WriteStats     `json:"writes"`
+}
+
+type restCameraPosition struct {
+	Horizontal           steering.HMovement `json:"horizontal"`
+	HorizontalPercentage float64            `json:"horizontalPercentage"`
+	Vertical             steering.VMovement `json:"vertical"`
+	VerticalPercentage   float64            `json:"verticalPercentage"`
+}
+
+type restError struct {
+	Error string             `json:"error"`
+	Code  steering.ErrorCode `json:"code,omitempty"`
+}
+
+//restQueued is the response to a queued movement. The status is the one before the movement was applied and the
+//previous error is the error of an earlier movement which could not be applied
+type restQueued struct {
+	restStatus
+	PreviousError *restError `json:"previousError,omitempty"`
+}
+
+//NewRESTEngine will create a new REST engine for a car which will listen on the passed address (see network.ResolveAddress)
+//on behalf of the user who started it
+func NewRESTEngine(c *Car, address string, identity stream.Identity) *RESTEngine {
+	return &RESTEngine{Address: address, Identity: identity, car: c}
+}
+
+//Start will start the http server. Every movement is passed to the step callback
+func (e *RESTEngine) Start(ctx context.Context, sc steering.StepCallback) error {
+	ctx, err := e.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/openapi.json", e.only("GET", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPI)
+	}))
+	mux.HandleFunc("/api/status", e.only("GET", func(w http.ResponseWriter, r *http.Request) {
+		doWriteJSON(w, http.StatusOK, e.doStatus())
+	}))
+	mux.HandleFunc("/api/latency", e.only("GET", func(w http.ResponseWriter, r *http.Request) {
+		doWriteJSON(w, http.StatusOK, doLatency())
+	}))
+	mux.HandleFunc("/api/move", e.only("POST", e.steer(func(w http.ResponseWriter, r *http.Request) {
+		var step steering.Step
+		if !doReadJSON(w, r, &step) {
+			return
+		}
+		e.doMove(w, sc, &step)
+	})))
+	mux.HandleFunc("/api/stop", e.only("POST", e.steer(func(w http.ResponseWriter, r *http.Request) {
+		step := e.car.State().Step
+		step.Speed = 0
+		e.doMove(w, sc, &step)
+	})))
+	mux.HandleFunc("/api/steering/center", e.only("POST", e.steer(func(w http.ResponseWriter, r *http.Request) {
+		step := e.car.State().Step
+		step.CarMovement = steering.HMovementNone
+		step.CarMovementPercentage = 0
+		e.doMove(w, sc, &step)
+	})))
+	mux.HandleFunc("/api/camera", e.only("PUT", e.steer(func(w http.ResponseWriter, r *http.Request) {
+		var pos restCameraPosition
+		if !doReadJSON(w, r, &pos) {
+			return
+		}
+		step := e.car.State().Step
+		step.CameraHMovement = pos.Horizontal
+		step.CameraHPercentage = pos.HorizontalPercentage
+		step.CameraVMovement = pos.Vertical
+		step.CameraVPercentage = pos.VerticalPercentage
+		e.doMove(w, sc, &step)
+	})))
+	mux.HandleFunc("/api/calibration", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			doWriteJSON(w, http.StatusOK, e.car.Calibration())
+		case "PUT":
+			if !e.Identity.Allowed(stream.PermissionCalibrate) {
+				doWriteJSON(w, http.StatusForbidden, restError{Error: fmt.Sprintf("User: %v is not allowed to calibrate the car", e.Identity.User)})
+				return
+			}
+			var cal Calibration
+			if !doReadJSON(w, r, &cal) {
+				return
+			}
+			err := e.car.SetCalibration(cal)
+			if err != nil {
+				doWriteJSON(w, http.StatusUnprocessableEntity, restError{Error: err.Error()})
+				return
+			}
+			doWriteJSON(w, http.StatusOK, e.car.Calibration())
+		default:
+			doWriteJSON(w, http.StatusMethodNotAllowed, restError{Error: "Method not allowed: " + r.Method})
+		}
+	})
+
+	address := e.Address
+	if address == "" {
+		address = DefaultRESTAddress
+	}
+	listener, err := network.Listen("tcp", address)
+	if err != nil {
+		return e.Abort(fmt.Errorf("Could not start REST-Steering Engine. Error: %v", err))
+	}
+
+	server := &http.Server{Handler: mux}
+	log.Printf("Serving REST API on: %v\n", listener.Addr())
+	e.Go(ctx, func(ctx context.Context) error {
+		go func() {
+			<-ctx.Done()
+			server.Close()
+		}()
+
+		err := server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			return fmt.Errorf("Error while serving REST-Steering Engine: %v", err)
+		}
+		return nil
+	})
+
+	return nil
+}
+
+func (e *RESTEngine) only(method string, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			doWriteJSON(w, http.StatusMethodNotAllowed, restError{Error: "Method not allowed: " + r.Method})
+			return
+		}
+		f(w, r)
+	}
+}
+
+func (e *RESTEngine) doStatus() restStatus {
+	state := e.car.State()
+	return restStatus{
+		State:      state,
+		Calibrated: state.Flags&steering.StatusCalibrated != 0,
+		Moving:     state.Flags&steering.StatusMoving != 0,
+		Failed:     state.Flags&steering.StatusError != 0,
+		Writes:     e.car.WriteStats(),
+	}
+}
+
+type restLatency struct {
+	Name  string  `json:"name"`
+	Count uint64  `json:"count"`
+	Mean  float64 `json:"meanMs"`
+	P50   float64 `json:"p50Ms"`
+	P90   float64 `json:"p90Ms"`
+	P99   float64 `json:"p99Ms"`
+	Max   float64 `json:"maxMs"`
+	Last  float64 `json:"lastMs"`
+}
+
+func doLatency() []restLatency {
+	var result []restLatency
+	for _, h := range latency.All() {
+		s := h.Snapshot()
+		result = append(result, restLatency{
+			Name:  s.Name,
+			Count: s.Count,
+			Mean:  doMilliseconds(s.Mean()),
+			P50:   doMilliseconds(s.Quantile(0.5)),
+			P90:   doMilliseconds(s.Quantile(0.9)),
+			P99:   doMilliseconds(s.Quantile(0.99)),
+			Max:   doMilliseconds(s.Max),
+			Last:  doMilliseconds(s.Last),
+		})
+	}
+
+	return result
+}
+
+func doMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+//doMove will validate the step and queue it. The step callback only returns the error of a previously applied step, so it
+//is reported as previous error while the step itself is accepted
+func (e *RESTEngine) doMove(w http.ResponseWriter, sc steering.StepCallback, step *steering.Step) {
+	validated := *step
+	err := e.car.Validate(&validated)
+	if err != nil {
+		doWriteJSON(w, http.StatusUnprocessableEntity, restError{Error: err.Error(), Code: steering.ErrorCodeOf(err)})
+		return
+	}
+
+	queued := restQueued{restStatus: e.doStatus()}
+	err = sc(step)
+	if err != nil {
+		queued.PreviousError = &restError{Error: err.Error(), Code: steering.ErrorCodeOf(err)}
+	}
+
+	doWriteJSON(w, http.StatusAccepted, queued)
+}
+
+//steer will only call the handler if the identity of the engine is allowed to steer the car
+func (e *RESTEngine) steer(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !e.Identity.Allowed(stream.PermissionSteer) {
+			doWriteJSON(w, http.StatusForbidden, restError{Error: fmt.Sprintf("User: %v is not allowed to steer the car", e.Identity.User)})
+			return
+		}
+		f(w, r)
+	}
+}
+
+func doReadJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		doWriteJSON(w, http.StatusBadRequest, restError{Error: "Could not parse request body. Error: " + err.Error()})
+		return false
+	}
+
+	return true
+}
+
+func doWriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("Could not write REST response. Error: %v\n", err)
+	}
+}
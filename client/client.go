@@ -0,0 +1,193 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"sdmimaye.de/smart-video-car/steering"
+)
+
+//DefaultHeartbeat is the interval in which the current step is repeated while the heartbeat is running
+const DefaultHeartbeat = 200 * time.Millisecond
+
+//Client will steer a car over its UDP steering engine. All values are percentages from -100 to 100: a negative speed
+//drives backwards, a negative steering steers left, a negative pan turns the camera left and a negative tilt down.
+//Every command sends the complete step with a new sequence number and the current time to measure the latency
+type Client struct {
+	conn     *net.UDPConn
+	mutex    sync.Mutex
+	state    steering.MissionState
+	sequence uint32
+	stop     chan struct{}
+}
+
+//Dial will create a client for the UDP steering engine of a car (e.g. 192.168.0.10:1338)
+func Dial(address string) (*Client, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("Could not resolve car address: %v. Error: %v", address, err)
+	}
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to car: %v. Error: %v", address, err)
+	}
+
+	return &Client{conn: conn}, nil
+}
+
+//Drive will set the speed of the car
+func (c *Client) Drive(speed float64) error {
+	return c.doUpdate(func(s *steering.MissionState) { s.Speed = speed })
+}
+
+//Steer will set the steering of the car
+func (c *Client) Steer(value float64) error {
+	return c.doUpdate(func(s *steering.MissionState) { s.Steering = value })
+}
+
+//Camera will set the pan and tilt of the camera
+func (c *Client) Camera(pan float64, tilt float64) error {
+	return c.doUpdate(func(s *steering.MissionState) { s.Pan, s.Tilt = pan, tilt })
+}
+
+//Stop will stop the motor and keep steering and camera
+func (c *Client) Stop() error {
+	return c.Drive(0)
+}
+
+//Send will send a complete step. Speed, steering and camera of the client are taken from the step
+func (c *Client) Send(step steering.Step) error {
+	state := steering.MissionState{Speed: step.Speed}
+	state.Steering = doSigned(step.CarMovement == steering.HMovementLeft, step.CarMovementPercentage)
+	state.Pan = doSigned(step.CameraHMovement == steering.HMovementLeft, step.CameraHPercentage)
+	state.Tilt = doSigned(step.CameraVMovement == steering.VMovementDown, step.CameraVPercentage)
+
+	return c.doUpdate(func(s *steering.MissionState) { *s = state })
+}
+
+func doSigned(negative bool, percentage float64) float64 {
+	if negative {
+		return -percentage
+	}
+
+	return percentage
+}
+
+func (c *Client) doUpdate(update func(s *steering.MissionState)) error {
+	c.mutex.Lock()
+	update(&c.state)
+	c.mutex.Unlock()
+
+	return c.doSend()
+}
+
+func (c *Client) doSend() error {
+	c.mutex.Lock()
+	c.sequence++
+	step := c.state.Step()
+	step.Sequence = c.sequence
+	c.mutex.Unlock()
+	step.Timestamp = time.Now().UnixNano()
+
+	_, err := c.conn.Write(steering.EncodeStep(step))
+	if err != nil {
+		return fmt.Errorf("Could not send step to car. Error: %v", err)
+	}
+
+	return nil
+}
+
+//Step will return the step which is currently sent to the car
+func (c *Client) Step() steering.Step {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return *c.state.Step()
+}
+
+//Sequence will return the sequence number of the last step which was sent
+func (c *Client) Sequence() uint32 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.sequence
+}
+
+//StartHeartbeat will repeat the current step in an interval (zero for DefaultHeartbeat). This keeps the car from
+//handing over control or stopping because of a timeout while the driver does not change anything
+func (c *Client) StartHeartbeat(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultHeartbeat
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.stop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	c.stop = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				c.doSend()
+			}
+		}
+	}()
+}
+
+//StopHeartbeat will stop repeating the current step
+func (c *Client) StopHeartbeat() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.stop == nil {
+		return
+	}
+
+	close(c.stop)
+	c.stop = nil
+}
+
+//ReadAck will wait for the next acknowledgement of the car. A timeout of zero waits forever
+func (c *Client) ReadAck(timeout time.Duration) (*steering.Ack, error) {
+	deadline := time.Time{}
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	c.conn.SetReadDeadline(deadline)
+
+	buffer := make([]byte, steering.AckSize+steering.AckLatencySize)
+	n, err := c.conn.Read(buffer)
+	if err != nil {
+		var e net.Error
+		if errors.As(err, &e) && e.Timeout() {
+			return nil, fmt.Errorf("No acknowledgement received within %v", timeout)
+		}
+		return nil, fmt.Errorf("Could not read acknowledgement. Error: %v", err)
+	}
+
+	return steering.ParseAck(buffer[:n])
+}
+
+//RoundTrip will return the time since the step of an acknowledgement was sent or zero if the car did not echo the timestamp
+func RoundTrip(ack *steering.Ack) time.Duration {
+	if ack.Latency.Timestamp == 0 {
+		return 0
+	}
+
+	return time.Since(time.Unix(0, ack.Latency.Timestamp))
+}
+
+//Close will stop the heartbeat and close the connection to the car
+func (c *Client) Close() error {
+	c.StopHeartbeat()
+	return c.conn.Close()
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"sdmimaye.de/smart-video-car/steering"
+)
+
+func doFreeUDPAddress(t *testing.T) string {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Could not find free UDP port. Error: %v", err)
+	}
+	defer conn.Close()
+
+	return conn.LocalAddr().String()
+}
+
+func TestRoundTripWithLatency(t *testing.T) {
+	address := doFreeUDPAddress(t)
+	engine := steering.UDPEngine{Address: address, AckEvery: 1}
+	received := make(chan steering.Step, 1)
+	err := engine.Start(context.Background(), func(step *steering.Step) error {
+		received <- *step
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Could not start UDP engine. Error: %v", err)
+	}
+	defer engine.Stop()
+
+	c, err := Dial(address)
+	if err != nil {
+		t.Fatalf("Could not dial UDP engine. Error: %v", err)
+	}
+	defer c.Close()
+
+	err = c.Drive(50)
+	if err != nil {
+		t.Fatalf("Could not send step. Error: %v", err)
+	}
+	ack, err := c.ReadAck(time.Second)
+	if err != nil {
+		t.Fatalf("Could not read acknowledgement. Error: %v", err)
+	}
+
+	step := <-received
+	if step.Speed != 50 {
+		t.Errorf("Expected speed 50 but got: %v", step.Speed)
+	}
+	if ack.Sequence != c.Sequence() {
+		t.Errorf("Expected sequence %v but got: %v", c.Sequence(), ack.Sequence)
+	}
+	if ack.Latency.Timestamp != step.Timestamp || ack.Latency.Timestamp == 0 {
+		t.Errorf("Expected echoed timestamp %v but got: %v", step.Timestamp, ack.Latency.Timestamp)
+	}
+	if RoundTrip(ack) <= 0 {
+		t.Errorf("Expected a positive round trip but got: %v", RoundTrip(ack))
+	}
+}
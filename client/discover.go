@@ -0,0 +1,84 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"sdmimaye.de/smart-video-car/network"
+)
+
+//DiscoveredCar is a car which announced itself on the local network
+type DiscoveredCar struct {
+	IP           net.IP
+	Announcement network.Announcement
+	Seen         time.Time
+}
+
+//Address will return the address of an announced port of the car (e.g. for UDP steering) or an empty string
+func (c DiscoveredCar) Address(port int) string {
+	if port <= 0 {
+		return ""
+	}
+
+	return net.JoinHostPort(c.IP.String(), fmt.Sprint(port))
+}
+
+//String will return a human readable summary of the car
+func (c DiscoveredCar) String() string {
+	a := c.Announcement
+	calibrated := "not calibrated"
+	if a.Calibrated {
+		calibrated = "calibrated"
+	}
+
+	return fmt.Sprintf("%v (%v) protocol v%v, udp: %v, websocket: %v, rest: %v, console: %v, %v",
+		a.Name, c.IP, a.Version, a.UDP, a.WebSocket, a.REST, a.Console, calibrated)
+}
+
+//Discover will listen for announcements on the beacon group (an empty group uses network.DefaultBeaconGroup) for the
+//passed duration and return every car which announced itself. Cars are identified by their address and name
+func Discover(group string, duration time.Duration) ([]DiscoveredCar, error) {
+	if group == "" {
+		group = network.DefaultBeaconGroup
+	}
+	addr, err := net.ResolveUDPAddr("udp4", group)
+	if err != nil {
+		return nil, fmt.Errorf("Could not resolve beacon group: %v. Error: %v", group, err)
+	}
+	socket, err := net.ListenMulticastUDP("udp4", nil, addr)
+	if err != nil {
+		return nil, fmt.Errorf("Could not listen for announcements. Error: %v", err)
+	}
+	defer socket.Close()
+
+	var cars []DiscoveredCar
+	known := make(map[string]int)
+	deadline := time.Now().Add(duration)
+	socket.SetReadDeadline(deadline)
+	buffer := make([]byte, 1500)
+	for time.Now().Before(deadline) {
+		n, remote, err := socket.ReadFromUDP(buffer)
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				break
+			}
+			return cars, fmt.Errorf("Error while listening for announcements: %v", err)
+		}
+		announcement, err := network.ParseAnnouncement(buffer[:n])
+		if err != nil {
+			continue
+		}
+
+		car := DiscoveredCar{IP: remote.IP, Announcement: *announcement, Seen: time.Now()}
+		key := remote.IP.String() + "/" + announcement.Name
+		if i, ok := known[key]; ok {
+			cars[i] = car
+			continue
+		}
+		known[key] = len(cars)
+		cars = append(cars, car)
+	}
+
+	return cars, nil
+}
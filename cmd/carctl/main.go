@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"sdmimaye.de/smart-video-car/client"
+	"sdmimaye.de/smart-video-car/steering"
+	"sdmimaye.de/smart-video-car/stream"
+)
+
+const usage = `Usage: carctl [flags] <command> [arguments]
+
+Commands:
+  discover               list all cars on the local network
+  hash                   read a password or token from stdin and print its hash
+                         for the users file of the car
+  drive <speed>          set the speed (-100 to 100)
+  steer <value>          set the steering (-100 left to 100 right)
+  camera <pan> <tilt>    set the camera (-100 to 100)
+  stop                   stop the motor
+  send <json>            send a complete step, e.g. '{"speed":50}'
+  run                    read commands line by line from stdin (with heartbeat),
+                         additionally supports wait <duration> and quit
+
+Flags:
+`
+
+func main() {
+	address := flag.String("a", "", "The address of the UDP steering engine (e.g. 192.168.0.10:1338). Empty to use the first discovered car")
+	timeout := flag.Duration("t", time.Second, "How long to wait for acknowledgements and announcements. Zero to not wait for acknowledgements")
+	heartbeat := flag.Duration("h", client.DefaultHeartbeat, "The heartbeat interval of the run command")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	args := flag.Args()
+	if args[0] == "hash" {
+		err := doHash()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if args[0] == "discover" {
+		err := doDiscover(*timeout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	c, err := doConnect(*address, *timeout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+
+	if args[0] == "run" {
+		err = doRun(c, *heartbeat, *timeout)
+	} else {
+		err = doCommand(c, args, *timeout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func doHash() error {
+	fmt.Fprint(os.Stderr, "Password or token: ")
+	secret, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && secret == "" {
+		return fmt.Errorf("Could not read password or token. Error: %v", err)
+	}
+
+	hash, err := stream.HashSecret(strings.TrimRight(secret, "\r\n"))
+	if err != nil {
+		return err
+	}
+	fmt.Println(hash)
+	return nil
+}
+
+func doDiscover(timeout time.Duration) error {
+	cars, err := client.Discover("", timeout)
+	if err != nil {
+		return err
+	}
+	if len(cars) == 0 {
+		return fmt.Errorf("No car found within %v", timeout)
+	}
+
+	for _, car := range cars {
+		fmt.Println(car)
+	}
+	return nil
+}
+
+func doConnect(address string, timeout time.Duration) (*client.Client, error) {
+	if address != "" {
+		return client.Dial(address)
+	}
+
+	cars, err := client.Discover("", timeout)
+	if err != nil {
+		return nil, err
+	}
+	for _, car := range cars {
+		if len(car.Announcement.UDP) > 0 {
+			log.Printf("Using discovered car: %v\n", car)
+			return client.Dial(car.Address(car.Announcement.UDP[0]))
+		}
+	}
+
+	return nil, fmt.Errorf("No car with UDP steering found within %v. Use -a to pass an address", timeout)
+}
+
+func doCommand(c *client.Client, args []string, timeout time.Duration) error {
+	values := make([]float64, len(args)-1)
+	for i, arg := range args[1:] {
+		if args[0] == "send" {
+			break
+		}
+		value, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid value for %v: %v", args[0], arg)
+		}
+		values[i] = value
+	}
+
+	var err error
+	switch {
+	case args[0] == "drive" && len(values) == 1:
+		err = c.Drive(values[0])
+	case args[0] == "steer" && len(values) == 1:
+		err = c.Steer(values[0])
+	case args[0] == "camera" && len(values) == 2:
+		err = c.Camera(values[0], values[1])
+	case args[0] == "stop" && len(values) == 0:
+		err = c.Stop()
+	case args[0] == "send" && len(args) == 2:
+		var step steering.Step
+		err = json.Unmarshal([]byte(args[1]), &step)
+		if err != nil {
+			return fmt.Errorf("Could not parse step: %v. Error: %v", args[1], err)
+		}
+		err = c.Send(step)
+	default:
+		return fmt.Errorf("Unknown command or wrong number of arguments: %v", strings.Join(args, " "))
+	}
+	if err != nil {
+		return err
+	}
+
+	return doAwaitAck(c, timeout)
+}
+
+func doAwaitAck(c *client.Client, timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+
+	sequence := c.Sequence()
+	for {
+		ack, err := c.ReadAck(timeout)
+		if err != nil {
+			return err
+		}
+		if ack.Sequence != sequence {
+			continue
+		}
+		if ack.Error != steering.ErrorNone {
+			return fmt.Errorf("Car rejected step %v with error code: %v", ack.Sequence, ack.Error)
+		}
+
+		fmt.Printf("Step %v applied. Flags: %v, Speed: %v, Round trip: %v (parse: %v, queue: %v, apply: %v)\n", ack.Sequence, ack.Flags,
+			ack.Step.Speed, client.RoundTrip(ack), ack.Latency.Parse, ack.Latency.Queue, ack.Latency.Apply)
+		return nil
+	}
+}
+
+func doRun(c *client.Client, heartbeat time.Duration, timeout time.Duration) error {
+	c.StartHeartbeat(heartbeat)
+	defer c.Stop()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "quit" || line == "exit" {
+			return nil
+		}
+
+		args := strings.SplitN(line, " ", 2)
+		if args[0] != "send" {
+			args = strings.Fields(line)
+		}
+		if args[0] == "wait" && len(args) == 2 {
+			d, err := time.ParseDuration(args[1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid duration: %v\n", args[1])
+				continue
+			}
+			time.Sleep(d)
+			continue
+		}
+
+		err := doCommand(c, args, timeout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -20,6 +20,21 @@ type CameraServoConfig struct {
 	sign  float64
 }
 
+//CameraDirectionCalibration determines which servo (index) moves the camera in a direction and with which sign
+type CameraDirectionCalibration struct {
+	Index int     `json:"index"`
+	Sign  float64 `json:"sign"`
+}
+
+//CameraCalibration represents the calibration of both camera servos and their directions
+type CameraCalibration struct {
+	Servos []ServoCalibration         `json:"servos"`
+	Up     CameraDirectionCalibration `json:"up"`
+	Down   CameraDirectionCalibration `json:"down"`
+	Left   CameraDirectionCalibration `json:"left"`
+	Right  CameraDirectionCalibration `json:"right"`
+}
+
 //CalibratedCamera controls the camera. It composes of two servo motors and the handle to communicate with the camera
 type CalibratedCamera struct {
 	servos []CalibratedServo
@@ -91,12 +106,16 @@ func NewCalibratedCamera() (*CalibratedCamera, error) {
 
 //Calibrate will calibrate the camera
 func (s *CalibratedCamera) Calibrate(stream stream.Stream) error {
+	previous := s.servos
 	s.servos = make([]CalibratedServo, len(servos))
 	for i, channel := range servos {
 		servo, err := NewCalibratedServo(channel)
 		if err != nil {
 			return fmt.Errorf("Could not create calibrated servo on channel: %v. Error: %v", channel, err)
 		}
+		if i < len(previous) {
+			servo.doKeepSettings(previous[i])
+		}
 
 		err = servo.Calibrate(stream)
 		if err != nil {
@@ -145,6 +164,10 @@ func (s *CalibratedCamera) Calibrate(stream stream.Stream) error {
 		s.servos[i] = *servo
 	}
 
+	return s.doSave()
+}
+
+func (s *CalibratedCamera) doSave() error {
 	cfg, section, err := doLoadIniWithMatchingSectionOrCreateEmptyForCamera()
 	if err != nil {
 		return fmt.Errorf("Could not Load/Save Ini file. Error: %v", err)
@@ -170,6 +193,72 @@ func (s *CalibratedCamera) Calibrate(stream stream.Stream) error {
 	return nil
 }
 
+//Calibration will return the current calibration of the camera
+func (s *CalibratedCamera) Calibration() CameraCalibration {
+	cal := CameraCalibration{Servos: make([]ServoCalibration, len(s.servos))}
+	for i := range s.servos {
+		cal.Servos[i] = s.servos[i].Calibration()
+	}
+	cal.Up = CameraDirectionCalibration{Index: s.up.index, Sign: s.up.sign}
+	cal.Down = CameraDirectionCalibration{Index: s.down.index, Sign: s.down.sign}
+	cal.Left = CameraDirectionCalibration{Index: s.left.index, Sign: s.left.sign}
+	cal.Right = CameraDirectionCalibration{Index: s.right.index, Sign: s.right.sign}
+
+	return cal
+}
+
+//ValidateCalibration will return an error if the calibration can not be applied to the camera
+func (s *CalibratedCamera) ValidateCalibration(cal CameraCalibration) error {
+	if len(cal.Servos) != len(s.servos) {
+		return fmt.Errorf("Invalid camera calibration. Expected %v servos but got: %v", len(s.servos), len(cal.Servos))
+	}
+	for i, servo := range cal.Servos {
+		err := servo.Validate()
+		if err != nil {
+			return fmt.Errorf("Invalid calibration of camera servo: %v. Error: %v", i, err)
+		}
+	}
+	for _, dir := range []CameraDirectionCalibration{cal.Up, cal.Down, cal.Left, cal.Right} {
+		if dir.Index < 0 || dir.Index >= len(s.servos) {
+			return fmt.Errorf("Invalid camera servo index: %v", dir.Index)
+		}
+	}
+
+	return nil
+}
+
+//SetCalibration will store a new calibration for the camera
+func (s *CalibratedCamera) SetCalibration(cal CameraCalibration) error {
+	err := s.ValidateCalibration(cal)
+	if err != nil {
+		return err
+	}
+
+	for i := range s.servos {
+		err := s.servos[i].SetCalibration(cal.Servos[i])
+		if err != nil {
+			return fmt.Errorf("Could not calibrate camera servo: %v. Error: %v", i, err)
+		}
+	}
+	s.up = CameraServoConfig{index: cal.Up.Index, sign: cal.Up.Sign}
+	s.down = CameraServoConfig{index: cal.Down.Index, sign: cal.Down.Sign}
+	s.left = CameraServoConfig{index: cal.Left.Index, sign: cal.Left.Sign}
+	s.right = CameraServoConfig{index: cal.Right.Index, sign: cal.Right.Sign}
+
+	return s.doSave()
+}
+
+//Calibrated will return true if both camera servos and their directions are calibrated
+func (s *CalibratedCamera) Calibrated() bool {
+	for i := range s.servos {
+		if !s.servos[i].Calibrated() {
+			return false
+		}
+	}
+
+	return s.up.sign != 0 && s.down.sign != 0 && s.left.sign != 0 && s.right.sign != 0
+}
+
 //CenterLeftRight will move the camera in the home position (left/rigth)
 func (s *CalibratedCamera) CenterLeftRight() error {
 	return s.servos[s.left.index].Home()
@@ -199,3 +288,27 @@ func (s *CalibratedCamera) MoveLeft(percent float64) error {
 func (s *CalibratedCamera) MoveRight(percent float64) error {
 	return s.servos[s.right.index].Move(percent, s.right.sign)
 }
+
+//SetHysteresis will set the number of degrees the camera servos have to move before their angles are written
+func (s *CalibratedCamera) SetHysteresis(degrees int) {
+	for i := range s.servos {
+		s.servos[i].SetHysteresis(degrees)
+	}
+}
+
+//WriteStats will return the counters of written and avoided angle writes of both camera servos
+func (s *CalibratedCamera) WriteStats() WriteStats {
+	var stats WriteStats
+	for i := range s.servos {
+		stats = stats.Add(s.servos[i].WriteStats())
+	}
+
+	return stats
+}
+
+//Invalidate will forget the last commanded angles of both camera servos
+func (s *CalibratedCamera) Invalidate() {
+	for i := range s.servos {
+		s.servos[i].Invalidate()
+	}
+}
@@ -21,6 +21,8 @@ const (
 	motor1Pin1     = 22
 	speedPwmMotor1 = 4
 	speedPwmMotor0 = 5
+	//maxSpeedPwm is the off time of full speed. 4096 would set the full off bit of the PCA9685 and stop the motor
+	maxSpeedPwm = 4095
 )
 
 type motorCabling int
@@ -37,10 +39,23 @@ type motor struct {
 	speedPwmChannel int
 }
 
-//CalibratedMotor represents a calibrated motor inside our smart car. It composes out of 4 gpio pins and 2 pwn signals
+//MotorCalibration represents the cabling of both motors (0: pin 0 forward, 1: pin 1 forward)
+type MotorCalibration struct {
+	M0Cabling int `json:"m0Cabling"`
+	M1Cabling int `json:"m1Cabling"`
+}
+
+//CalibratedMotor represents a calibrated motor inside our smart car. It composes out of 4 gpio pins and 2 pwn signals.
+//The pwm signals and the direction pins are only written if they differ from the last successfully written values
 type CalibratedMotor struct {
-	m0 motor
-	m1 motor
+	m0        motor
+	m1        motor
+	pwm       int
+	direction int
+	pwmKnown  bool
+	pinsKnown bool
+	pwmWrites *writeCounter
+	pinWrites *writeCounter
 }
 
 func doLoadIniWithMatchingSectionOrCreateEmptyForMotor() (*ini.File, *ini.Section, error) {
@@ -114,7 +129,7 @@ func NewCalibratedMotor() (*CalibratedMotor, error) {
 
 	m0 := motor{p0: m0p0, p1: m0p1, speedPwmChannel: speedPwmMotor0, cabling: motorCabling(m0cab)}
 	m1 := motor{p0: m1p0, p1: m1p1, speedPwmChannel: speedPwmMotor1, cabling: motorCabling(m1cab)}
-	motor := CalibratedMotor{m0: m0, m1: m1}
+	motor := CalibratedMotor{m0: m0, m1: m1, pwmWrites: &writeCounter{}, pinWrites: &writeCounter{}}
 	return &motor, nil
 }
 
@@ -124,6 +139,7 @@ func (m *CalibratedMotor) Calibrate(stream stream.Stream) error {
 	w := stream.GetWriter()
 
 	reader := bufio.NewReader(r)
+	m.Invalidate()
 	fmt.Fprint(w, "The first wheel will move in one direction. Please pay attention!\r\n")
 	err := hardware.SetPwmValue(m.m0.speedPwmChannel, 0, 2000)
 	if err != nil {
@@ -173,65 +189,140 @@ func (m *CalibratedMotor) Calibrate(stream stream.Stream) error {
 		return fmt.Errorf("Could not set pin %v to low. Error: %v", motor1Pin0, err)
 	}
 
+	return m.doSave()
+}
+
+func (m *CalibratedMotor) doSave() error {
+	cfg, section, err := doLoadIniWithMatchingSectionOrCreateEmptyForMotor()
+	if err != nil {
+		return fmt.Errorf("Could not load motor config from ini. Error: %v", err)
+	}
+
+	section.NewKey("M0Cabling", fmt.Sprintf("%v", int(m.m0.cabling)))
+	section.NewKey("M1Cabling", fmt.Sprintf("%v", int(m.m1.cabling)))
+
+	err = cfg.SaveTo(CalibrationFilePath)
+	if err != nil {
+		return fmt.Errorf("Could not store configuration for motor. Error: %v", err)
+	}
+
 	return nil
 }
 
+//Calibration will return the current calibration of the motor
+func (m *CalibratedMotor) Calibration() MotorCalibration {
+	return MotorCalibration{M0Cabling: int(m.m0.cabling), M1Cabling: int(m.m1.cabling)}
+}
+
+//ValidateCalibration will return an error if the calibration can not be applied to the motor
+func (m *CalibratedMotor) ValidateCalibration(cal MotorCalibration) error {
+	for _, cabling := range []int{cal.M0Cabling, cal.M1Cabling} {
+		if motorCabling(cabling) != p0ForwardP1Backward && motorCabling(cabling) != p1ForwardP0Backward {
+			return fmt.Errorf("Invalid motor cabling: %v. Use either 0 or 1", cabling)
+		}
+	}
+
+	return nil
+}
+
+//SetCalibration will store a new calibration for the motor
+func (m *CalibratedMotor) SetCalibration(cal MotorCalibration) error {
+	err := m.ValidateCalibration(cal)
+	if err != nil {
+		return err
+	}
+
+	m.m0.cabling = motorCabling(cal.M0Cabling)
+	m.m1.cabling = motorCabling(cal.M1Cabling)
+	m.Invalidate()
+	return m.doSave()
+}
+
 //SetSpeed will set the speed of the motor
 func (m *CalibratedMotor) SetSpeed(speedPercentage float64) error {
 	if speedPercentage > 100 || speedPercentage < -100 {
 		return fmt.Errorf("Invalid speed percentage value: %v. Choose a valud between 100 and -100", speedPercentage)
 	}
 
-	pwm := int(math.Abs(4096 * speedPercentage))
-	log.Printf("Motor PWM: %v\n", pwm)
+	pwm := doSpeedPwm(speedPercentage)
+	if hardware.Verbose {
+		log.Printf("Motor PWM: %v\n", pwm)
+	}
 
-	err0 := hardware.SetPwmValue(m.m0.speedPwmChannel, 0, pwm)
-	err1 := hardware.SetPwmValue(m.m1.speedPwmChannel, 0, pwm)
+	changed := !m.pwmKnown || pwm != m.pwm
+	m.pwmWrites.doCount(changed, 2)
+	if changed {
+		m.pwmKnown = false
+		err0 := hardware.SetPwmValue(m.m0.speedPwmChannel, 0, pwm)
+		err1 := hardware.SetPwmValue(m.m1.speedPwmChannel, 0, pwm)
 
-	if err0 != nil || err1 != nil {
-		return fmt.Errorf("Could not set motor speed to: %v percent. Errors: %v, %v", speedPercentage, err0, err1)
+		if err0 != nil || err1 != nil {
+			return fmt.Errorf("Could not set motor speed to: %v percent. Errors: %v, %v", speedPercentage, err0, err1)
+		}
+		m.pwm, m.pwmKnown = pwm, true
 	}
 
-	if speedPercentage > 0 { //we are moving forward
-		if m.m0.cabling == p0ForwardP1Backward {
-			m.m0.p0.Write(hardware.High)
-			m.m0.p1.Write(hardware.Low)
-		} else if m.m0.cabling == p1ForwardP0Backward {
-			m.m0.p0.Write(hardware.Low)
-			m.m0.p1.Write(hardware.High)
-		}
+	direction := 0
+	if speedPercentage > 0 {
+		direction = 1
+	} else if speedPercentage < 0 {
+		direction = -1
+	}
+	changed = !m.pinsKnown || direction != m.direction
+	m.pinWrites.doCount(changed, 4)
+	if !changed {
+		return nil
+	}
+
+	m.pinsKnown = false
+	err := m.m0.doSetDirection(direction)
+	if err == nil {
+		err = m.m1.doSetDirection(direction)
+	}
+	if err != nil {
+		return fmt.Errorf("Could not set motor direction for speed: %v percent. Error: %v", speedPercentage, err)
+	}
+	m.direction, m.pinsKnown = direction, true
+
+	return nil
+}
+
+//doSpeedPwm will return the pwm off time of a speed percentage (-100 to 100)
+func doSpeedPwm(speedPercentage float64) int {
+	return int(math.Abs(maxSpeedPwm * speedPercentage / 100))
+}
 
-		if m.m1.cabling == p0ForwardP1Backward {
-			m.m1.p0.Write(hardware.High)
-			m.m1.p1.Write(hardware.Low)
-		} else if m.m0.cabling == p1ForwardP0Backward {
-			m.m1.p0.Write(hardware.Low)
-			m.m1.p1.Write(hardware.High)
+//doSetDirection will write the direction pins of one motor according to its cabling (1: forward, -1: backward, 0: stop)
+func (mo motor) doSetDirection(direction int) error {
+	p0, p1 := hardware.Low, hardware.Low
+	if direction != 0 {
+		forward := direction > 0
+		if mo.cabling == p1ForwardP0Backward {
+			forward = !forward
 		}
-	} else if speedPercentage < 0 { //we are moving backward
-		if m.m0.cabling == p0ForwardP1Backward {
-			m.m0.p0.Write(hardware.Low)
-			m.m0.p1.Write(hardware.High)
-		} else if m.m0.cabling == p1ForwardP0Backward {
-			m.m0.p0.Write(hardware.High)
-			m.m0.p1.Write(hardware.Low)
+		if forward {
+			p0 = hardware.High
+		} else {
+			p1 = hardware.High
 		}
+	}
 
-		if m.m1.cabling == p0ForwardP1Backward {
-			m.m1.p0.Write(hardware.Low)
-			m.m1.p1.Write(hardware.High)
-		} else if m.m0.cabling == p1ForwardP0Backward {
-			m.m1.p0.Write(hardware.High)
-			m.m1.p1.Write(hardware.Low)
-		}
-	} else { //we stopped
-		m.m0.p0.Write(hardware.Low)
-		m.m0.p1.Write(hardware.Low)
-		m.m1.p0.Write(hardware.Low)
-		m.m1.p1.Write(hardware.Low)
+	err := mo.p0.Write(p0)
+	if err != nil {
+		return err
 	}
+	return mo.p1.Write(p1)
+}
 
-	return nil
+//WriteStats will return the counters of written and avoided pwm writes and direction pin writes
+func (m *CalibratedMotor) WriteStats() (pwm WriteStats, pins WriteStats) {
+	return m.pwmWrites.stats(), m.pinWrites.stats()
+}
+
+//Invalidate will forget the last written pwm and direction, so that the next speed is written in any case
+func (m *CalibratedMotor) Invalidate() {
+	m.pwmKnown = false
+	m.pinsKnown = false
 }
 
 //Stop will halt all movemnt of the motor
@@ -0,0 +1,27 @@
+package components
+
+import "testing"
+
+//TestSpeedPwm will check that full speed in both directions does not set the full off bit of the PCA9685
+func TestSpeedPwm(t *testing.T) {
+	tests := []struct {
+		speed float64
+		pwm   int
+	}{
+		{100, 4095},
+		{-100, 4095},
+		{50, 2047},
+		{-50, 2047},
+		{0, 0},
+	}
+
+	for _, test := range tests {
+		pwm := doSpeedPwm(test.speed)
+		if pwm != test.pwm {
+			t.Errorf("Speed: %v has pwm: %v, expected: %v", test.speed, pwm, test.pwm)
+		}
+		if pwm>>8&0x10 != 0 {
+			t.Errorf("Speed: %v sets the full off bit (pwm: %v)", test.speed, pwm)
+		}
+	}
+}
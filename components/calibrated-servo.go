@@ -20,18 +20,75 @@ const (
 	ServoCalibrationPrefix = "Servo"
 )
 
-//CalibratedServo represents a calibrateable servo in a smart car
+//ServoCalibration represents the calibrated angles of a servo
+type ServoCalibration struct {
+	Min    int `json:"min"`
+	Max    int `json:"max"`
+	Center int `json:"center"`
+}
+
+//CalibratedServo represents a calibrateable servo in a smart car. An angle is only written if it differs from the last
+//commanded angle by more than the hysteresis
 type CalibratedServo struct {
-	channel int
-	servo   hardware.ServoMotor
-	min     int
-	max     int
-	center  int
+	channel    int
+	servo      hardware.ServoMotor
+	min        int
+	max        int
+	center     int
+	hysteresis int
+	angle      int
+	commanded  bool
+	writes     *writeCounter
 }
 
 //Home will move the servo in a centered direction (percentual to the maximum calibrated value)
 func (s *CalibratedServo) Home() error {
-	return s.servo.SetAngle(s.center)
+	return s.doSetAngle(s.center)
+}
+
+//SetHysteresis will set the number of degrees an angle has to differ from the last commanded angle to be written
+func (s *CalibratedServo) SetHysteresis(degrees int) {
+	s.hysteresis = degrees
+}
+
+//doKeepSettings will take over the hysteresis and the write counters of the servo which is replaced by this one
+func (s *CalibratedServo) doKeepSettings(previous CalibratedServo) {
+	s.hysteresis = previous.hysteresis
+	if previous.writes != nil {
+		s.writes = previous.writes
+	}
+}
+
+//WriteStats will return the counters of written and avoided angle writes
+func (s *CalibratedServo) WriteStats() WriteStats {
+	return s.writes.stats()
+}
+
+//Invalidate will forget the last commanded angle, so that the next angle is written in any case
+func (s *CalibratedServo) Invalidate() {
+	s.commanded = false
+}
+
+func (s *CalibratedServo) doSetAngle(angle int) error {
+	delta := angle - s.angle
+	if delta < 0 {
+		delta = -delta
+	}
+	if s.commanded && delta <= s.hysteresis {
+		s.writes.doCount(false, 1)
+		return nil
+	}
+
+	s.writes.doCount(true, 1)
+	err := s.servo.SetAngle(angle)
+	s.angle = angle
+	s.commanded = err == nil
+	return err
+}
+
+//Calibrated will return true if the servo has a calibrated range of motion
+func (s *CalibratedServo) Calibrated() bool {
+	return s.min != s.max
 }
 
 func doCalculatePercentOfAndSteer(s *CalibratedServo, percent float64) error {
@@ -48,7 +105,7 @@ func doCalculatePercentOfAndSteer(s *CalibratedServo, percent float64) error {
 		return errors.New("Invalid percentual value for Servo")
 	}
 
-	return s.servo.SetAngle(int(value))
+	return s.doSetAngle(int(value))
 }
 
 //Forward will move the servo in a forward direction (percentual to the maximum calibrated value)
@@ -68,7 +125,9 @@ func (s *CalibratedServo) Move(percent float64, direction float64) error {
 	} else {
 		direction = 1
 	}
-	log.Printf("Movement of Servo: %v, Direction: %v, Percent: %v", s.channel, direction, percent)
+	if hardware.Verbose {
+		log.Printf("Movement of Servo: %v, Direction: %v, Percent: %v", s.channel, direction, percent)
+	}
 
 	return doCalculatePercentOfAndSteer(s, percent*direction)
 }
@@ -148,6 +207,17 @@ func (s *CalibratedServo) Calibrate(stream stream.Stream) error {
 	s.max = doDetermineAngle(r, w, s.servo, s.max, "Max")
 	s.center = doDetermineAngle(r, w, s.servo, s.center, "Center")
 
+	err := s.doSave()
+	if err != nil {
+		return err
+	}
+	s.commanded = false
+	s.doSetAngle(s.center)
+
+	return nil
+}
+
+func (s *CalibratedServo) doSave() error {
 	cfg, section, err := doLoadIniWithMatchingSectionOrCreateEmptyForServo(s.channel)
 	if err != nil {
 		return fmt.Errorf("Could not read config file for servo: %v. Error: %v", s.channel, err)
@@ -161,11 +231,53 @@ func (s *CalibratedServo) Calibrate(stream stream.Stream) error {
 	if err != nil {
 		return fmt.Errorf("Could not store configuration for servo: %v. Error: %v", s.channel, err)
 	}
-	s.servo.SetAngle(s.center)
 
 	return nil
 }
 
+//Calibration will return the current calibration of the servo
+func (s *CalibratedServo) Calibration() ServoCalibration {
+	return ServoCalibration{Min: s.min, Max: s.max, Center: s.center}
+}
+
+//Validate will return an error if an angle is outside 0 to 180 degrees, min is not below max or the center is not
+//between them
+func (cal ServoCalibration) Validate() error {
+	for _, angle := range []int{cal.Min, cal.Max, cal.Center} {
+		if angle < 0 || angle > 180 {
+			return fmt.Errorf("Invalid servo angle: %v. Choose a value between 0 and 180", angle)
+		}
+	}
+	if cal.Min >= cal.Max {
+		return fmt.Errorf("Invalid servo range: min: %v, max: %v. Min has to be below max", cal.Min, cal.Max)
+	}
+	if cal.Center < cal.Min || cal.Center > cal.Max {
+		return fmt.Errorf("Invalid servo center: %v. Choose a value between min: %v and max: %v", cal.Center, cal.Min, cal.Max)
+	}
+
+	return nil
+}
+
+//SetCalibration will store a new calibration for the servo and move it in the home position
+func (s *CalibratedServo) SetCalibration(cal ServoCalibration) error {
+	err := cal.Validate()
+	if err != nil {
+		return err
+	}
+
+	s.min = cal.Min
+	s.max = cal.Max
+	s.center = cal.Center
+
+	err = s.doSave()
+	if err != nil {
+		return err
+	}
+
+	s.commanded = false
+	return s.Home()
+}
+
 //NewCalibratedServo will create a new calibrated servo. If no calibration file is present a calibration will be initiated
 func NewCalibratedServo(channel int) (*CalibratedServo, error) {
 	servo, err := hardware.GetServo(channel)
@@ -179,7 +291,7 @@ func NewCalibratedServo(channel int) (*CalibratedServo, error) {
 		return nil, fmt.Errorf("Error while loading ini file for servo on channel: %v, Error: %v", channel, err)
 	}
 
-	srv := CalibratedServo{channel: channel, servo: servo}
+	srv := CalibratedServo{channel: channel, servo: servo, writes: &writeCounter{}}
 	srv.min, _ = sec.Key("Min").Int()
 	srv.max, _ = sec.Key("Max").Int()
 	srv.center, _ = sec.Key("Center").Int()
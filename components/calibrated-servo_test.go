@@ -0,0 +1,29 @@
+package components
+
+import "testing"
+
+//TestServoCalibrationValidate will check that angles outside 0 to 180, an empty range and an outside center are rejected
+func TestServoCalibrationValidate(t *testing.T) {
+	tests := []struct {
+		cal   ServoCalibration
+		valid bool
+	}{
+		{ServoCalibration{Min: 0, Max: 180, Center: 90}, true},
+		{ServoCalibration{Min: 40, Max: 140, Center: 40}, true},
+		{ServoCalibration{Min: 40, Max: 140, Center: 140}, true},
+		{ServoCalibration{Min: -1, Max: 140, Center: 90}, false},
+		{ServoCalibration{Min: 40, Max: 181, Center: 90}, false},
+		{ServoCalibration{Min: 90, Max: 90, Center: 90}, false},
+		{ServoCalibration{Min: 140, Max: 40, Center: 90}, false},
+		{ServoCalibration{Min: 40, Max: 140, Center: 30}, false},
+		{ServoCalibration{Min: 40, Max: 140, Center: 150}, false},
+		{ServoCalibration{}, false},
+	}
+
+	for _, test := range tests {
+		err := test.cal.Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("Validate(%+v) returned: %v, expected valid: %v", test.cal, err, test.valid)
+		}
+	}
+}
@@ -16,6 +16,13 @@ const (
 	servoIndex = 0
 )
 
+//SteeringCalibration represents the calibration of the steering. Left and Right are the servo directions (1 or -1)
+type SteeringCalibration struct {
+	Servo ServoCalibration `json:"servo"`
+	Left  float64          `json:"left"`
+	Right float64          `json:"right"`
+}
+
 //CalibratedSteering controls the vehicle steering. It composes of one servo motors and the required configuration
 type CalibratedSteering struct {
 	servo CalibratedServo
@@ -68,6 +75,7 @@ func (c *CalibratedSteering) Calibrate(stream stream.Stream) error {
 	if err != nil {
 		return fmt.Errorf("Steering: Could not create calibrated servo on channel: %v. Error: %v", servoIndex, err)
 	}
+	servo.doKeepSettings(c.servo)
 
 	err = servo.Calibrate(stream)
 	if err != nil {
@@ -106,6 +114,16 @@ func (c *CalibratedSteering) Calibrate(stream stream.Stream) error {
 		}
 	}
 
+	err = c.doSave()
+	if err != nil {
+		return err
+	}
+	c.servo = *servo
+
+	return nil
+}
+
+func (c *CalibratedSteering) doSave() error {
 	cfg, section, err := doLoadIniWithMatchingSectionOrCreateEmptyForSteering()
 	if err != nil {
 		return fmt.Errorf("Could not create config file for steering. Error: %v", err)
@@ -122,6 +140,46 @@ func (c *CalibratedSteering) Calibrate(stream stream.Stream) error {
 	return nil
 }
 
+//Calibration will return the current calibration of the steering
+func (c *CalibratedSteering) Calibration() SteeringCalibration {
+	return SteeringCalibration{Servo: c.servo.Calibration(), Left: c.left, Right: c.right}
+}
+
+//ValidateCalibration will return an error if the calibration can not be applied to the steering
+func (c *CalibratedSteering) ValidateCalibration(cal SteeringCalibration) error {
+	if cal.Left*cal.Right != -1 {
+		return fmt.Errorf("Invalid steering directions: left: %v, right: %v. Use either 1 and -1 or -1 and 1", cal.Left, cal.Right)
+	}
+	err := cal.Servo.Validate()
+	if err != nil {
+		return fmt.Errorf("Invalid steering servo calibration. Error: %v", err)
+	}
+
+	return nil
+}
+
+//SetCalibration will store a new calibration for the steering
+func (c *CalibratedSteering) SetCalibration(cal SteeringCalibration) error {
+	err := c.ValidateCalibration(cal)
+	if err != nil {
+		return err
+	}
+
+	err = c.servo.SetCalibration(cal.Servo)
+	if err != nil {
+		return fmt.Errorf("Could not calibrate steering servo. Error: %v", err)
+	}
+
+	c.left = cal.Left
+	c.right = cal.Right
+	return c.doSave()
+}
+
+//Calibrated will return true if the steering servo and its directions are calibrated
+func (c *CalibratedSteering) Calibrated() bool {
+	return c.servo.Calibrated() && c.left != 0 && c.right != 0
+}
+
 //Center will reset the steering and move in the home position
 func (c *CalibratedSteering) Center() error {
 	return c.servo.Home()
@@ -136,3 +194,18 @@ func (c *CalibratedSteering) SteerLeft(percent float64) error {
 func (c *CalibratedSteering) SteerRight(percent float64) error {
 	return c.servo.Move(percent, c.right)
 }
+
+//SetHysteresis will set the number of degrees the steering servo has to move before its angle is written
+func (c *CalibratedSteering) SetHysteresis(degrees int) {
+	c.servo.SetHysteresis(degrees)
+}
+
+//WriteStats will return the counters of written and avoided angle writes of the steering servo
+func (c *CalibratedSteering) WriteStats() WriteStats {
+	return c.servo.WriteStats()
+}
+
+//Invalidate will forget the last commanded angle of the steering servo
+func (c *CalibratedSteering) Invalidate() {
+	c.servo.Invalidate()
+}
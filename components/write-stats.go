@@ -0,0 +1,33 @@
+package components
+
+import "sync/atomic"
+
+//WriteStats counts the hardware writes of an actuator. Avoided are the writes which were skipped because the actuator
+//already had (or was within the hysteresis of) the commanded value
+type WriteStats struct {
+	Written uint64 `json:"written"`
+	Avoided uint64 `json:"avoided"`
+}
+
+//Add will return the sum of both stats
+func (s WriteStats) Add(o WriteStats) WriteStats {
+	return WriteStats{Written: s.Written + o.Written, Avoided: s.Avoided + o.Avoided}
+}
+
+//writeCounter is shared by all copies of a component, so the counters survive copying the component by value
+type writeCounter struct {
+	written atomic.Uint64
+	avoided atomic.Uint64
+}
+
+func (c *writeCounter) doCount(written bool, n uint64) {
+	if written {
+		c.written.Add(n)
+	} else {
+		c.avoided.Add(n)
+	}
+}
+
+func (c *writeCounter) stats() WriteStats {
+	return WriteStats{Written: c.written.Load(), Avoided: c.avoided.Load()}
+}
@@ -0,0 +1,118 @@
+package hardware
+
+import (
+	"sync"
+	"time"
+
+	"sdmimaye.de/smart-video-car/latency"
+)
+
+const (
+	//pwmChannels is the number of channels of the PCA9685
+	pwmChannels = 16
+	//pwmControlPoints is the resolution of a pwm period
+	pwmControlPoints = 4096
+	//servoFrequency is the pwm frequency of the servo controller in Hz
+	servoFrequency = 50
+	//servoMinMicroseconds and servoMaxMicroseconds are the pulse widths for 0 and 180 degrees
+	servoMinMicroseconds = 544
+	servoMaxMicroseconds = 2400
+)
+
+type pwmUpdate struct {
+	on  int
+	off int
+}
+
+var (
+	batchMutex   sync.Mutex
+	pendingMutex sync.Mutex
+	batching     bool
+	pending      [pwmChannels]pwmUpdate
+	pendingSet   [pwmChannels]bool
+)
+
+//Batch collects all pwm and servo updates until it is flushed, so that they can be written together. While a batch is
+//open SetPwmValue and SetAngle only remember the last value per channel. Only one batch can be open at a time.
+//A batch is not bound to the goroutine which opened it, every write of the process is collected while it is open. So
+//all actuator writes have to be serialized by their owner (car.Car), otherwise a write of another goroutine is delayed
+//until the batch is flushed
+type Batch struct {
+	flushed bool
+}
+
+//BeginBatch will open a new batch. It waits until the previously opened batch was flushed
+func BeginBatch() *Batch {
+	batchMutex.Lock()
+	pendingMutex.Lock()
+	batching = true
+	pendingMutex.Unlock()
+
+	return &Batch{}
+}
+
+//Flush will write all collected updates and close the batch. Adjacent channels are written in one auto-increment
+//transaction, so that they change at the same time
+func (b *Batch) Flush() error {
+	if b.flushed {
+		return nil
+	}
+	b.flushed = true
+	defer batchMutex.Unlock()
+
+	pendingMutex.Lock()
+	batching = false
+	updates, set := pending, pendingSet
+	pendingSet = [pwmChannels]bool{}
+	pendingMutex.Unlock()
+
+	defer latency.I2C.Since(time.Now())
+	var result error
+	for first := 0; first < pwmChannels; first++ {
+		if !set[first] {
+			continue
+		}
+		last := first
+		for last+1 < pwmChannels && set[last+1] {
+			last++
+		}
+
+		err := doWritePwmRun(first, updates[first:last+1])
+		if err != nil && result == nil {
+			result = err
+		}
+		first = last
+	}
+
+	return result
+}
+
+//doQueuePwm will remember the update if a batch is open and return false otherwise. It does not know who opened the
+//batch (see Batch)
+func doQueuePwm(channel int, onTime int, offTime int) bool {
+	pendingMutex.Lock()
+	defer pendingMutex.Unlock()
+	if !batching || channel < 0 || channel >= pwmChannels {
+		return false
+	}
+
+	pending[channel] = pwmUpdate{on: onTime, off: offTime}
+	pendingSet[channel] = true
+	return true
+}
+
+//doServoOffTime will calculate the pwm off time of a servo angle like the servo driver does
+func doServoOffTime(angle int, minus int, maxus int) int {
+	us := angle*(maxus-minus)/180 + minus
+	return us * servoFrequency * pwmControlPoints / 1000000
+}
+
+//doEncodePwmRun will encode the on and off times of adjacent channels in register order (on low, on high, off low, off high)
+func doEncodePwmRun(updates []pwmUpdate) []byte {
+	data := make([]byte, 0, 4*len(updates))
+	for _, u := range updates {
+		data = append(data, byte(u.on), byte(u.on>>8), byte(u.off), byte(u.off>>8))
+	}
+
+	return data
+}
@@ -0,0 +1,54 @@
+package hardware
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+//BenchmarkEncodePwmRun will encode the registers of the two motor channels
+func BenchmarkEncodePwmRun(b *testing.B) {
+	b.ReportAllocs()
+	updates := []pwmUpdate{{on: 0, off: 2048}, {on: 0, off: 2048}}
+	for i := 0; i < b.N; i++ {
+		doEncodePwmRun(updates)
+	}
+}
+
+//BenchmarkBatch will write one actuation of the car (motor pwm, steering and camera servos) in a batch. It is skipped
+//if the servo controller can not be initialized
+func BenchmarkBatch(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	err := InitializeServoController()
+	if err != nil {
+		b.Skipf("No servo controller. Error: %v", err)
+	}
+	defer DeInitializeServoController()
+
+	var servos []ServoMotor
+	for _, channel := range []int{0, 14, 15} {
+		servo, err := GetServo(channel)
+		if err != nil {
+			b.Fatal(err)
+		}
+		servos = append(servos, servo)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		batch := BeginBatch()
+		SetPwmValue(4, 0, i%pwmControlPoints)
+		SetPwmValue(5, 0, i%pwmControlPoints)
+		for _, servo := range servos {
+			servo.SetAngle(i % 180)
+		}
+		err = batch.Flush()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,5 +1,8 @@
 package hardware
 
+//Verbose enables logging of every servo, pwm and GPIO access. It is off by default to keep the control path cheap
+var Verbose = false
+
 //ServoMotor will abstract a servor motor controlled by an servo controller in this application
 type ServoMotor interface {
 	SetAngle(angle int) error
@@ -3,6 +3,9 @@ package hardware
 import (
 	"errors"
 	"log"
+	"time"
+
+	"sdmimaye.de/smart-video-car/latency"
 )
 
 var servoInitialized = false
@@ -20,7 +23,13 @@ type DarwinPin struct {
 
 //SetAngle will set the angle of a Darwin servo motor
 func (s DarwinServoMotor) SetAngle(angle int) error {
-	log.Printf("Setting Angle of Servo (with Channel:%v) to: %v\n", s.channel, angle)
+	defer latency.Servo.Since(time.Now())
+	if doQueuePwm(s.channel, 0, doServoOffTime(angle, servoMinMicroseconds, servoMaxMicroseconds)) {
+		return nil
+	}
+	if Verbose {
+		log.Printf("Setting Angle of Servo (with Channel:%v) to: %v\n", s.channel, angle)
+	}
 	return nil
 }
 
@@ -32,13 +41,17 @@ func (p DarwinPin) SetDirection(direction PinDirection) error {
 
 //Read will read the current level of a (fake Darwin) GPIO pin
 func (p DarwinPin) Read() (PinLevel, error) {
-	log.Printf("Reading value of pin: %v\n", p.pin)
+	if Verbose {
+		log.Printf("Reading value of pin: %v\n", p.pin)
+	}
 	return Low, nil
 }
 
 //Write will read the current level of a GPIO pin
 func (p DarwinPin) Write(level PinLevel) error {
-	log.Printf("Writing value: %v of pin: %v\n", level, p.pin)
+	if Verbose {
+		log.Printf("Writing value: %v of pin: %v\n", level, p.pin)
+	}
 	return nil
 }
 
@@ -88,10 +101,26 @@ func GetPin(pin int) (Pin, error) {
 
 //SetPwmValue will set the PWM Value on a channel
 func SetPwmValue(channel int, onTime int, offTime int) error {
+	defer latency.PWM.Since(time.Now())
+	if !servoInitialized {
+		return errors.New("Please initialize the (fake Darwin) I²C Controller before using pwm")
+	}
+
+	if Verbose {
+		log.Printf("Setting (fake Darwin) PWM Signal. Channel: %v, on: %v, off: %v\n", channel, onTime, offTime)
+	}
+	doQueuePwm(channel, onTime, offTime)
+	return nil
+}
+
+//doWritePwmRun will write the on and off times of adjacent (fake Darwin) channels in one transaction
+func doWritePwmRun(first int, updates []pwmUpdate) error {
 	if !servoInitialized {
 		return errors.New("Please initialize the (fake Darwin) I²C Controller before using pwm")
 	}
 
-	log.Printf("Setting (fake Darwin) PWM Signal. Channel: %v, on: %v, off: %v\n", channel, onTime, offTime)
+	if Verbose {
+		log.Printf("Writing (fake Darwin) PWM of channels: %v to %v: %v\n", first, first+len(updates)-1, doEncodePwmRun(updates))
+	}
 	return nil
 }
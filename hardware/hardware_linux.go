@@ -4,19 +4,32 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/kidoman/embd"
 	"github.com/kidoman/embd/controller/pca9685"
 
 	_ "github.com/kidoman/embd/host/all" //Otherwise this packae will not be loaded
 	"github.com/kidoman/embd/motion/servo"
+
+	"sdmimaye.de/smart-video-car/latency"
+)
+
+const (
+	pcaAddress        = 0x40
+	pcaMode1          = 0x00
+	pcaAutoIncrement  = 0x20
+	pcaRestart        = 0x80
+	pcaLed0OnLow      = 0x06
+	pcaRegistersPerCh = 4
 )
 
 var pca *pca9685.PCA9685
 
 //LinuxServoMotor represents a Servo-Motor inside a linux enviroment
 type LinuxServoMotor struct {
-	servo *servo.Servo
+	channel int
+	servo   *servo.Servo
 }
 
 //LinuxPin represents a digital GPIO Pin
@@ -26,6 +39,10 @@ type LinuxPin struct {
 
 //SetAngle will set the angle of a linux servo motor
 func (s LinuxServoMotor) SetAngle(angle int) error {
+	defer latency.Servo.Since(time.Now())
+	if doQueuePwm(s.channel, 0, doServoOffTime(angle, s.servo.Minus, s.servo.Maxus)) {
+		return nil
+	}
 	return s.servo.SetAngle(angle)
 }
 
@@ -47,7 +64,9 @@ func (p LinuxPin) Read() (PinLevel, error) {
 
 //Write will read the current level of a GPIO pin
 func (p LinuxPin) Write(level PinLevel) error {
-	log.Printf("Writing: %v on gpio pin: %v", level, p.pin.N())
+	if Verbose {
+		log.Printf("Writing: %v on gpio pin: %v", level, p.pin.N())
+	}
 	err := p.pin.Write(int(level))
 	if err != nil {
 		return fmt.Errorf("Could not write to GPIO pin. Error: %v", err)
@@ -71,13 +90,22 @@ func InitializeServoController() error {
 
 	bus := embd.NewI2CBus(1)
 
-	pca = pca9685.New(bus, 0x40)
-	pca.Freq = 50
+	pca = pca9685.New(bus, pcaAddress)
+	pca.Freq = servoFrequency
 	err = pca.Wake()
 	if err != nil {
 		return fmt.Errorf("Could not wake pwm I²C Bus. Reason: %v", err)
 	}
 
+	mode1, err := bus.ReadByteFromReg(pcaAddress, pcaMode1)
+	if err != nil {
+		return fmt.Errorf("Could not read PCA mode. Reason: %v", err)
+	}
+	err = bus.WriteByteToReg(pcaAddress, pcaMode1, mode1&^pcaRestart|pcaAutoIncrement)
+	if err != nil {
+		return fmt.Errorf("Could not enable PCA register auto-increment. Reason: %v", err)
+	}
+
 	return nil
 }
 
@@ -139,7 +167,7 @@ func GetServo(channel int) (ServoMotor, error) {
 	ch := pca.ServoChannel(channel)
 	servo := servo.New(ch)
 
-	return LinuxServoMotor{servo: servo}, nil
+	return LinuxServoMotor{channel: channel, servo: servo}, nil
 }
 
 //GetPin will create a new GPIO Pin
@@ -155,10 +183,33 @@ func GetPin(pin int) (Pin, error) {
 
 //SetPwmValue will set the PWM Value on a channel
 func SetPwmValue(channel int, onTime int, offTime int) error {
+	defer latency.PWM.Since(time.Now())
 	if pca == nil {
 		return errors.New("Please initialize the I²C Controller before using pwm")
 	}
 
-	log.Printf("Setting PWM on channel: %v, on: %v, off: %v\n", channel, onTime, offTime)
+	if Verbose {
+		log.Printf("Setting PWM on channel: %v, on: %v, off: %v\n", channel, onTime, offTime)
+	}
+	if doQueuePwm(channel, onTime, offTime) {
+		return nil
+	}
 	return pca.SetPwm(channel, onTime, offTime)
 }
+
+//doWritePwmRun will write the on and off times of adjacent channels in one auto-increment transaction
+func doWritePwmRun(first int, updates []pwmUpdate) error {
+	if pca == nil {
+		return errors.New("Please initialize the I²C Controller before using pwm")
+	}
+
+	if Verbose {
+		log.Printf("Writing PWM of channels: %v to %v in one transaction\n", first, first+len(updates)-1)
+	}
+	err := pca.Bus.WriteToReg(pca.Addr, byte(pcaLed0OnLow+pcaRegistersPerCh*first), doEncodePwmRun(updates))
+	if err != nil {
+		return fmt.Errorf("Could not write PWM of channels: %v to %v. Error: %v", first, first+len(updates)-1, err)
+	}
+
+	return nil
+}
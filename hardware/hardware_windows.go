@@ -3,6 +3,9 @@ package hardware
 import (
 	"errors"
 	"log"
+	"time"
+
+	"sdmimaye.de/smart-video-car/latency"
 )
 
 var servoInitialized = false
@@ -20,7 +23,13 @@ type WindowsPin struct {
 
 //SetAngle will set the angle of a windows servo motor
 func (s WindowsServoMotor) SetAngle(angle int) error {
-	log.Printf("Setting Angle of Servo (with Channel:%v) to: %v\n", s.channel, angle)
+	defer latency.Servo.Since(time.Now())
+	if doQueuePwm(s.channel, 0, doServoOffTime(angle, servoMinMicroseconds, servoMaxMicroseconds)) {
+		return nil
+	}
+	if Verbose {
+		log.Printf("Setting Angle of Servo (with Channel:%v) to: %v\n", s.channel, angle)
+	}
 	return nil
 }
 
@@ -32,13 +41,17 @@ func (p WindowsPin) SetDirection(direction PinDirection) error {
 
 //Read will read the current level of a (fake Windows) GPIO pin
 func (p WindowsPin) Read() (PinLevel, error) {
-	log.Printf("Reading value of pin: %v\n", p.pin)
+	if Verbose {
+		log.Printf("Reading value of pin: %v\n", p.pin)
+	}
 	return Low, nil
 }
 
 //Write will read the current level of a GPIO pin
 func (p WindowsPin) Write(level PinLevel) error {
-	log.Printf("Writing value: %v of pin: %v\n", level, p.pin)
+	if Verbose {
+		log.Printf("Writing value: %v of pin: %v\n", level, p.pin)
+	}
 	return nil
 }
 
@@ -88,10 +101,26 @@ func GetPin(pin int) (Pin, error) {
 
 //SetPwmValue will set the PWM Value on a channel
 func SetPwmValue(channel int, onTime int, offTime int) error {
+	defer latency.PWM.Since(time.Now())
+	if !servoInitialized {
+		return errors.New("Please initialize the (fake Windows) I²C Controller before using pwm")
+	}
+
+	if Verbose {
+		log.Printf("Setting (fake Windows) PWM Signal. Channel: %v, on: %v, off: %v\n", channel, onTime, offTime)
+	}
+	doQueuePwm(channel, onTime, offTime)
+	return nil
+}
+
+//doWritePwmRun will write the on and off times of adjacent (fake Windows) channels in one transaction
+func doWritePwmRun(first int, updates []pwmUpdate) error {
 	if !servoInitialized {
 		return errors.New("Please initialize the (fake Windows) I²C Controller before using pwm")
 	}
 
-	log.Printf("Setting (fake Windows) PWM Signal. Channel: %v, on: %v, off: %v\n", channel, onTime, offTime)
+	if Verbose {
+		log.Printf("Writing (fake Windows) PWM of channels: %v to %v: %v\n", first, first+len(updates)-1, doEncodePwmRun(updates))
+	}
 	return nil
 }
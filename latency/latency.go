@@ -0,0 +1,148 @@
+package latency
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+//bucketCount is the number of histogram buckets. Bucket i counts durations below 2^i microseconds, the last bucket
+//counts everything above
+const bucketCount = 26
+
+//Histogram counts durations in power of two microsecond buckets. All methods are lock free and cheap enough to be
+//called for every step. The counters are placed first to keep them 64-bit aligned on 32-bit ARM
+type Histogram struct {
+	count   uint64
+	sum     uint64
+	max     uint64
+	last    uint64
+	buckets [bucketCount]uint64
+	Name    string
+}
+
+//Observe will add a duration to the histogram
+func (h *Histogram) Observe(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	ns := uint64(d)
+	index := bits.Len64(ns / uint64(time.Microsecond))
+	if index >= bucketCount {
+		index = bucketCount - 1
+	}
+
+	atomic.AddUint64(&h.buckets[index], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddUint64(&h.sum, ns)
+	atomic.StoreUint64(&h.last, ns)
+	for {
+		max := atomic.LoadUint64(&h.max)
+		if ns <= max || atomic.CompareAndSwapUint64(&h.max, max, ns) {
+			return
+		}
+	}
+}
+
+//Since will add the duration since start to the histogram. It is meant to be deferred: defer h.Since(time.Now())
+func (h *Histogram) Since(start time.Time) {
+	h.Observe(time.Since(start))
+}
+
+//Last will return the last observed duration
+func (h *Histogram) Last() time.Duration {
+	return time.Duration(atomic.LoadUint64(&h.last))
+}
+
+//Snapshot will return a copy of the current counters
+func (h *Histogram) Snapshot() Snapshot {
+	s := Snapshot{
+		Name:  h.Name,
+		Count: atomic.LoadUint64(&h.count),
+		Sum:   time.Duration(atomic.LoadUint64(&h.sum)),
+		Max:   time.Duration(atomic.LoadUint64(&h.max)),
+		Last:  time.Duration(atomic.LoadUint64(&h.last)),
+	}
+	for i := range h.buckets {
+		s.Buckets[i] = atomic.LoadUint64(&h.buckets[i])
+	}
+
+	return s
+}
+
+//Snapshot is a copy of the counters of a histogram
+type Snapshot struct {
+	Name    string
+	Count   uint64
+	Sum     time.Duration
+	Max     time.Duration
+	Last    time.Duration
+	Buckets [bucketCount]uint64
+}
+
+//Mean will return the average duration
+func (s Snapshot) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+
+	return s.Sum / time.Duration(s.Count)
+}
+
+//Quantile will return the upper bound of the bucket which contains the quantile q (0 to 1)
+func (s Snapshot) Quantile(q float64) time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+
+	rank := uint64(q * float64(s.Count))
+	seen := uint64(0)
+	for i, count := range s.Buckets {
+		seen += count
+		if seen > rank && i < bucketCount-1 {
+			return time.Duration(uint64(1)<<uint(i)) * time.Microsecond
+		}
+	}
+
+	return s.Max
+}
+
+func (s Snapshot) String() string {
+	return fmt.Sprintf("%-8v count: %v, mean: %v, p50: <%v, p90: <%v, p99: <%v, max: %v, last: %v",
+		s.Name, s.Count, s.Mean(), s.Quantile(0.5), s.Quantile(0.9), s.Quantile(0.99), s.Max, s.Last)
+}
+
+var (
+	//Network measures the time from the client timestamp of a step until it was received. It is only meaningful if the
+	//clocks of client and car are synchronized
+	Network = &Histogram{Name: "network"}
+	//Parse measures the time from receiving a step until it was parsed
+	Parse = &Histogram{Name: "parse"}
+	//Queue measures the time a step waited in the mailbox
+	Queue = &Histogram{Name: "queue"}
+	//Apply measures the time the car needed to apply a step
+	Apply = &Histogram{Name: "apply"}
+	//Servo measures every servo write (SetAngle). Inside a batch only the time to queue the write is measured
+	Servo = &Histogram{Name: "servo"}
+	//PWM measures every pwm write of the motor (SetPwmValue)
+	PWM = &Histogram{Name: "pwm"}
+	//I2C measures the time a batch of pwm and servo updates needed to be written
+	I2C = &Histogram{Name: "i2c"}
+)
+
+//All will return all histograms in the order of the control path
+func All() []*Histogram {
+	return []*Histogram{Network, Parse, Queue, Apply, Servo, PWM, I2C}
+}
+
+//Report will return a summary of all histograms, one line per histogram
+func Report() string {
+	var lines []string
+	for _, h := range All() {
+		lines = append(lines, h.Snapshot().String())
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -5,11 +5,15 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"sdmimaye.de/smart-video-car/car"
 	"sdmimaye.de/smart-video-car/hardware"
+	"sdmimaye.de/smart-video-car/network"
+	"sdmimaye.de/smart-video-car/steering"
 	"sdmimaye.de/smart-video-car/stream"
 )
 
@@ -27,36 +31,210 @@ func main() {
 	}
 	defer hardware.DeInitializeMotorController()
 
+	//the server is passed to the signal handler once it was created, so that its sessions can be closed first
+	servers := make(chan *stream.TCPServer, 1)
 	c := make(chan os.Signal, 2)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
+		select {
+		case server := <-servers:
+			log.Println("Closing all TCP sessions...")
+			go server.Close()
+			<-c
+		default:
+		}
 		hardware.DeInitializeMotorController()
 		hardware.DeInitializeServoController()
 		os.Exit(1)
 	}()
 
+	execution := flag.String("e", "console", "The execution type of the application. Valid values are: console, tcp, ssh, unix or serial:<device>[:<baud rate>[:<line settings>]] (e.g. serial:/dev/ttyUSB0:115200:8N1)")
+	validation := flag.String("v", "reject", "How steps with values out of range are handled. Valid values are: reject or clamp")
+	tcpAddress := flag.String("tcp", ":1337", "The listen address of the tcp execution (e.g. :1337, 127.0.0.1:1337, [::1]:1337 or wlan0:1337)")
+	tlsCertificate := flag.String("tls-cert", "", "The PEM certificate of the tcp execution. If set only TLS connections are accepted")
+	tlsKey := flag.String("tls-key", "", "The PEM key of the tls certificate")
+	tlsClientCA := flag.String("tls-ca", "", "The PEM CA client certificates have to be signed by (mutual TLS). Empty to accept every client")
+	sshAddress := flag.String("ssh", ":2222", "The listen address of the ssh execution")
+	sshHostKey := flag.String("ssh-hostkey", "ssh_host_ed25519_key", "The private host key of the ssh execution (generated if missing)")
+	sshKeys := flag.String("ssh-keys", "authorized_keys", "The authorized_keys file with the public keys which may log in via ssh without a users file. With a users file every user has its own AuthorizedKeys")
+	unixPath := flag.String("unix", "smart-video-car.sock", "The socket file of the unix execution")
+	unixMode := flag.String("unix-mode", "0660", "The permissions of the socket file of the unix execution (octal)")
+	unixUsers := flag.String("unix-users", "", "Comma separated names or ids of the users which may connect to the unix execution")
+	unixGroups := flag.String("unix-groups", "", "Comma separated names or ids of the groups which may connect to the unix execution. Without users and groups only the user of the car may connect")
+	users := flag.String("users", "", "The ini file which maps logins (e.g. the common name of a client certificate) to users, their permissions and password hashes")
+	login := flag.Bool("login", false, "Ask every tcp, ssh, unix or serial session for a login and a password or token of the users file")
+	sessionPolicy := flag.String("session", "exclusive", "How the tcp, ssh and unix execution treat several clients. Valid values are: exclusive, shared or observer")
+	udpAddresses := flag.String("udp", steering.DefaultUDPAddress, "Comma separated listen addresses of the UDP steering engines (one engine per address)")
+	wsAddress := flag.String("ws", steering.DefaultWebSocketAddress, "The listen address of the WebSocket steering engine")
+	restAddress := flag.String("http", car.DefaultRESTAddress, "The listen address of the REST steering engine")
+	name := flag.String("name", "", "The name the car is announced with on the local network (empty for the hostname)")
+	beaconGroup := flag.String("beacon", network.DefaultBeaconGroup, "The multicast group the car is announced on. Use off to disable the announcement")
+	hysteresis := flag.Int("hysteresis", 0, "The number of degrees a servo has to move before its angle is written (0 writes every change)")
+	verbose := flag.Bool("verbose", false, "Log every received step and every hardware access (slows down the control path)")
+	flag.Parse()
+
+	steering.Verbose = *verbose
+	hardware.Verbose = *verbose
+
 	car, err := car.NewCar()
 	if err != nil {
 		log.Panicf("Could not create new smart car instance. Error: %v", err)
 	}
-	execution := flag.String("e", "console", "The execution type of the application. Valid values are: console or tcp")
-	flag.Parse()
+	car.SetServoHysteresis(*hysteresis)
+	car.Endpoints.UDP = network.SplitList(*udpAddresses)
+	car.Endpoints.WebSocket = *wsAddress
+	car.Endpoints.REST = *restAddress
+	car.Name = *name
+	if car.Name == "" {
+		car.Name, _ = os.Hostname()
+	}
+
+	if strings.HasPrefix(*validation, "clamp") {
+		car.Validation = steering.ValidationClamp
+	} else if !strings.HasPrefix(*validation, "reject") {
+		log.Panicf("Unknown validation mode: %v. Will exit now! (Valid values are: reject or clamp)\n", *validation)
+	}
+
+	var accounts stream.Users
+	if *users != "" {
+		accounts, err = stream.LoadUsers(*users)
+		if err != nil {
+			log.Panicf("Could not load users. Error: %v", err)
+		}
+	} else if *login {
+		log.Panicf("The login requires a users file. Please pass it with -users")
+	}
 
-	var s stream.Stream
+	var server *stream.TCPServer
+	var serial *stream.SerialStream
 	if execution == nil || strings.HasPrefix(*execution, "console") { //fallback to console
 		log.Println("Will start console execution...")
-		s = stream.ConsoleStream{}
 	} else if strings.HasPrefix(*execution, "tcp") {
 		log.Println("Will start tcp execution...")
-		s, err = stream.NewTCPStream(1337)
+		policy, err := stream.ParseSessionPolicy(*sessionPolicy)
+		if err != nil {
+			log.Panicf("Could not start new TCP Server. Error: %v", err)
+		}
+		if *tlsCertificate == "" {
+			server, err = stream.NewTCPServer(*tcpAddress, policy)
+		} else {
+			config := stream.TLSConfig{Certificate: *tlsCertificate, Key: *tlsKey, ClientCA: *tlsClientCA, Users: accounts}
+			server, err = stream.NewTLSServer(*tcpAddress, policy, config)
+		}
+		if err != nil {
+			log.Panicf("Could not start new TCP Server on: %v. Error: %v", *tcpAddress, err)
+		}
+		car.Endpoints.Console = *tcpAddress
+	} else if strings.HasPrefix(*execution, "ssh") {
+		log.Println("Will start ssh execution...")
+		policy, err := stream.ParseSessionPolicy(*sessionPolicy)
+		if err != nil {
+			log.Panicf("Could not start new SSH Server. Error: %v", err)
+		}
+		config := stream.SSHConfig{HostKey: *sshHostKey, AuthorizedKeys: *sshKeys, Users: accounts}
+		server, err = stream.NewSSHServer(*sshAddress, policy, config)
+		if err != nil {
+			log.Panicf("Could not start new SSH Server on: %v. Error: %v", *sshAddress, err)
+		}
+		car.Endpoints.Console = *sshAddress
+	} else if strings.HasPrefix(*execution, "unix") {
+		log.Println("Will start unix execution...")
+		policy, err := stream.ParseSessionPolicy(*sessionPolicy)
+		if err != nil {
+			log.Panicf("Could not start new Unix Server. Error: %v", err)
+		}
+		mode, err := strconv.ParseUint(*unixMode, 8, 32)
+		if err != nil {
+			log.Panicf("Invalid permissions of the unix socket: %v. Error: %v", *unixMode, err)
+		}
+		config := stream.UnixConfig{Path: *unixPath, Mode: os.FileMode(mode), Users: network.SplitList(*unixUsers), Groups: network.SplitList(*unixGroups)}
+		server, err = stream.NewUnixServer(config, policy)
+		if err != nil {
+			log.Panicf("Could not start new Unix Server on: %v. Error: %v", *unixPath, err)
+		}
+	} else if strings.HasPrefix(*execution, "serial") {
+		log.Println("Will start serial execution...")
+		config, err := stream.ParseSerialConfig(*execution)
 		if err != nil {
-			log.Panicf("Could not start new TCP Server on port 1337")
+			log.Panicf("Could not start serial console. Error: %v", err)
 		}
+		serial, err = stream.OpenSerial(config)
+		if err != nil {
+			log.Panicf("Could not start serial console on: %v. Error: %v", config, err)
+		}
+		defer serial.Close()
 	} else {
-		log.Panicf("Unknown execution type: %v. Will exit now! (Valid values are: console or tcp)\n", *execution)
+		log.Panicf("Unknown execution type: %v. Will exit now! (Valid values are: console, tcp, ssh, unix or serial:<device>)\n", *execution)
+	}
+
+	if *beaconGroup != "off" {
+		beacon := network.Beacon{Group: *beaconGroup, Announce: car.Announcement}
+		err = beacon.Start()
+		if err != nil {
+			log.Printf("Car will not be announced on the local network. Error: %v\n", err)
+		} else {
+			defer beacon.Stop()
+		}
+	}
+
+	if server != nil {
+		servers <- server
 	}
 
 	log.Printf("Exeuction: %v\n", *execution)
-	car.Listen(s)
+	limiter := stream.NewLoginLimiter()
+	if serial != nil {
+		doServeSerial(car, serial, *login, accounts, limiter)
+		return
+	}
+	if server == nil {
+		car.Listen(stream.ConsoleStream{})
+		return
+	}
+
+	log.Printf("TCP session policy: %v\n", server.Policy)
+	err = server.Serve(func(session *stream.TCPSession) {
+		var s stream.Stream = session
+		if *login {
+			authenticated, err := stream.Login(session, accounts, limiter)
+			if err != nil {
+				log.Printf("Closing TCP session: %v. Error: %v\n", session, err)
+				return
+			}
+			s = authenticated
+		}
+
+		if session.Observer() {
+			car.Observe(s)
+			return
+		}
+		car.Listen(s)
+	})
+	if err != stream.ErrServerClosed {
+		log.Printf("TCP Server stopped. Error: %v\n", err)
+	}
+}
+
+//doServeSerial will restart the console on the serial device whenever it was left, until the device fails
+func doServeSerial(c *car.Car, serial *stream.SerialStream, login bool, accounts stream.Users, limiter *stream.LoginLimiter) {
+	for serial.Err() == nil {
+		var s stream.Stream = serial
+		if login {
+			authenticated, err := stream.Login(serial, accounts, limiter)
+			if err != nil {
+				log.Printf("Login on serial console: %v failed. Error: %v\n", serial.Config, err)
+				if err == stream.ErrLockedOut {
+					time.Sleep(limiter.Lockout)
+				}
+				continue
+			}
+			s = authenticated
+		}
+
+		c.Listen(s)
+		log.Printf("Left serial console: %v\n", serial.Config)
+	}
+
+	log.Printf("Serial console: %v closed. Error: %v\n", serial.Config, serial.Err())
 }
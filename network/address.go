@@ -0,0 +1,75 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+//ResolveAddress will translate a configured address of the form host:port into a listen address. The host can be empty
+//(all interfaces), an IPv4 or IPv6 address (e.g. 127.0.0.1:1338 or [::1]:1338), a hostname (e.g. localhost:1338)
+//or the name of a network interface (e.g. wlan0:1338). A bare port (e.g. 1338) listens on all interfaces
+func ResolveAddress(address string) (string, error) {
+	if !strings.Contains(address, ":") {
+		address = ":" + address
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", fmt.Errorf("Invalid address: %v. Error: %v", address, err)
+	}
+	if host == "" || net.ParseIP(host) != nil {
+		return address, nil
+	}
+
+	iface, err := net.InterfaceByName(host)
+	if err != nil {
+		return address, nil
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", fmt.Errorf("Could not read addresses of interface: %v. Error: %v", host, err)
+	}
+
+	var fallback net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ipnet.IP.To4() != nil {
+			return net.JoinHostPort(ipnet.IP.String(), port), nil
+		}
+		if fallback == nil && !ipnet.IP.IsLinkLocalUnicast() {
+			fallback = ipnet.IP
+		}
+	}
+	if fallback == nil {
+		return "", fmt.Errorf("Interface %v has no usable address", host)
+	}
+
+	return net.JoinHostPort(fallback.String(), port), nil
+}
+
+//SplitList will split a comma separated list of addresses
+func SplitList(list string) []string {
+	var addresses []string
+	for _, address := range strings.Split(list, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses
+}
+
+//Listen will resolve the address and start listening on it
+func Listen(protocol string, address string) (net.Listener, error) {
+	resolved, err := ResolveAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return net.Listen(protocol, resolved)
+}
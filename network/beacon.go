@@ -0,0 +1,153 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//BeaconService identifies the announcements of a smart-video-car
+const BeaconService = "smart-video-car"
+
+//ProtocolVersion is the version of the steering protocol announced by the car
+const ProtocolVersion = 1
+
+//DefaultBeaconGroup is the multicast group (and port) the beacon announces the car on. The announcement is also broadcasted
+//on the same port for networks without multicast routing
+const DefaultBeaconGroup = "239.255.13.37:1339"
+
+//DefaultBeaconInterval is the time between two announcements
+const DefaultBeaconInterval = 2 * time.Second
+
+//Announcement is sent periodically by the beacon. All ports are relative to the address the announcement was received from,
+//a port of zero means the endpoint is not available
+type Announcement struct {
+	Service    string `json:"service"`
+	Name       string `json:"name"`
+	Version    int    `json:"version"`
+	UDP        []int  `json:"udp,omitempty"`
+	WebSocket  int    `json:"webSocket,omitempty"`
+	REST       int    `json:"rest,omitempty"`
+	Console    int    `json:"console,omitempty"`
+	Calibrated bool   `json:"calibrated"`
+}
+
+//ParseAnnouncement will parse a received beacon packet
+func ParseAnnouncement(b []byte) (*Announcement, error) {
+	var a Announcement
+	err := json.Unmarshal(b, &a)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse announcement. Error: %v", err)
+	}
+	if a.Service != BeaconService {
+		return nil, fmt.Errorf("Unknown service: %v", a.Service)
+	}
+
+	return &a, nil
+}
+
+//Port will return the port of an address or zero if the address has none
+func Port(address string) int {
+	if !strings.Contains(address, ":") {
+		address = ":" + address
+	}
+
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return 0
+	}
+	value, err := strconv.Atoi(port)
+	if err != nil {
+		return 0
+	}
+
+	return value
+}
+
+//Beacon announces the car on the local network. Announce is called before every announcement to get the current state
+type Beacon struct {
+	Group    string
+	Interval time.Duration
+	Announce func() Announcement
+
+	stop chan struct{}
+}
+
+//Start will start announcing the car
+func (b *Beacon) Start() error {
+	if b.stop != nil {
+		log.Println("Beacon is already running...")
+		return nil
+	}
+
+	group := b.Group
+	if group == "" {
+		group = DefaultBeaconGroup
+	}
+	multicast, err := net.ResolveUDPAddr("udp4", group)
+	if err != nil {
+		return fmt.Errorf("Could not resolve beacon group: %v. Error: %v", group, err)
+	}
+	broadcast := &net.UDPAddr{IP: net.IPv4bcast, Port: multicast.Port}
+	socket, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return fmt.Errorf("Could not start beacon. Error: %v", err)
+	}
+	interval := b.Interval
+	if interval <= 0 {
+		interval = DefaultBeaconInterval
+	}
+
+	stop := make(chan struct{})
+	b.stop = stop
+	log.Printf("Announcing car on: %v\n", multicast)
+	go func() {
+		defer socket.Close()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			b.doAnnounce(socket, multicast, broadcast)
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (b *Beacon) doAnnounce(socket *net.UDPConn, targets ...*net.UDPAddr) {
+	announcement := b.Announce()
+	announcement.Service = BeaconService
+	announcement.Version = ProtocolVersion
+	packet, err := json.Marshal(announcement)
+	if err != nil {
+		log.Printf("Could not encode announcement. Error: %v\n", err)
+		return
+	}
+
+	for _, target := range targets {
+		_, err = socket.WriteToUDP(packet, target)
+		if err != nil {
+			log.Printf("Could not send announcement to: %v. Error: %v\n", target, err)
+		}
+	}
+}
+
+//Stop will stop announcing the car
+func (b *Beacon) Stop() error {
+	if b.stop == nil {
+		log.Println("Beacon has stopped already...")
+		return nil
+	}
+
+	close(b.stop)
+	b.stop = nil
+	return nil
+}
@@ -0,0 +1,141 @@
+package steering
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+//ErrorCode describes why a received command was not applied
+type ErrorCode uint8
+
+const (
+	//ErrorNone signals that the command was applied
+	ErrorNone ErrorCode = 0
+	//ErrorParse signals that the command bytes could not be parsed into a step
+	ErrorParse ErrorCode = 1
+	//ErrorMove signals that the car could not apply the step. Steps which are applied asynchronously (see Mailbox) report
+	//the error with the acknowledgement of the next step
+	ErrorMove ErrorCode = 2
+	//ErrorNotANumber signals that a value of the step is NaN or infinite
+	ErrorNotANumber ErrorCode = 3
+	//ErrorSpeedRange signals that the speed is outside of -100 to 100
+	ErrorSpeedRange ErrorCode = 4
+	//ErrorPercentageRange signals that a steering or camera percentage is outside of 0 to 100
+	ErrorPercentageRange ErrorCode = 5
+	//ErrorUnknownMovement signals that a steering or camera movement is unknown
+	ErrorUnknownMovement ErrorCode = 6
+	//ErrorOverridden signals that another source with a higher priority is in control of the car
+	ErrorOverridden ErrorCode = 7
+)
+
+//StatusFlags represents the current status of the car as a bit set
+type StatusFlags uint8
+
+const (
+	//StatusCalibrated is set when steering and camera are calibrated
+	StatusCalibrated StatusFlags = 1 << iota
+	//StatusMoving is set when the motor is running
+	StatusMoving
+	//StatusError is set when the last applied step could not be applied. Steps are applied asynchronously (see Mailbox),
+	//so it refers to the step applied before the acknowledged one
+	StatusError
+)
+
+//State represents the current state of the car. Step is the last step which was applied successfully
+type State struct {
+	Flags StatusFlags `json:"flags"`
+	Step  Step        `json:"step"`
+}
+
+//StateCallback is a callback function which will return the current state of the car
+type StateCallback func() State
+
+//AckSize is the size of an encoded acknowledgement in bytes
+const AckSize = 4 + 1 + 1 + StepSize
+
+//AckLatencySize is the size of the latency extension which is appended to the acknowledgement of a step with a timestamp
+const AckLatencySize = 8 + 4 + 4 + 4
+
+//AckLatency contains the latencies measured by the car. Timestamp is the echoed timestamp of the driver, Parse the time
+//the command needed to be parsed. The acknowledged step is applied asynchronously after the acknowledgement was prepared,
+//so Queue and Apply are the times the previously applied step waited for and needed to be applied by the car
+type AckLatency struct {
+	Timestamp int64
+	Parse     time.Duration
+	Queue     time.Duration
+	Apply     time.Duration
+}
+
+//Ack is the reply which will be sent back to the driver for a received command. Latency is only sent if the command
+//contained a timestamp. Parse and validation errors (ErrorNotANumber to ErrorUnknownMovement) and ErrorOverridden refer
+//to the acknowledged step. ErrorMove refers to a previously applied step, because the acknowledged step is applied after
+//the acknowledgement was sent
+type Ack struct {
+	Sequence uint32
+	Error    ErrorCode
+	Flags    StatusFlags
+	Step     Step
+	Latency  AckLatency
+}
+
+//Bytes will encode the acknowledgement in big endian byte order: sequence, error code, status flags and the applied step.
+//If the latency contains a timestamp the timestamp and the parse, queue and apply durations in microseconds are appended
+func (a *Ack) Bytes() []byte {
+	b := make([]byte, AckSize+AckLatencySize)
+	return b[:a.Put(b)]
+}
+
+//Put will encode the acknowledgement like Bytes into b without allocating and return the number of bytes written.
+//b has to be at least AckSize+AckLatencySize bytes long
+func (a *Ack) Put(b []byte) int {
+	binary.BigEndian.PutUint32(b[0:4], a.Sequence)
+	b[4] = byte(a.Error)
+	b[5] = byte(a.Flags)
+	putStep(b[6:], &a.Step)
+	if a.Latency.Timestamp == 0 {
+		return AckSize
+	}
+
+	l := b[AckSize:]
+	binary.BigEndian.PutUint64(l[0:8], uint64(a.Latency.Timestamp))
+	binary.BigEndian.PutUint32(l[8:12], doMicroseconds(a.Latency.Parse))
+	binary.BigEndian.PutUint32(l[12:16], doMicroseconds(a.Latency.Queue))
+	binary.BigEndian.PutUint32(l[16:20], doMicroseconds(a.Latency.Apply))
+	return AckSize + AckLatencySize
+}
+
+func doMicroseconds(d time.Duration) uint32 {
+	us := d / time.Microsecond
+	if us > math.MaxUint32 {
+		return math.MaxUint32
+	}
+
+	return uint32(us)
+}
+
+//ParseAck will parse an acknowledgement sent by the car or return an error
+func ParseAck(b []byte) (*Ack, error) {
+	if len(b) < AckSize {
+		return nil, fmt.Errorf("Acknowledgement is too short: %v bytes (expected %v)", len(b), AckSize)
+	}
+
+	ack := Ack{
+		Sequence: binary.BigEndian.Uint32(b[0:4]),
+		Error:    ErrorCode(b[4]),
+		Flags:    StatusFlags(b[5]),
+		Step:     getStep(b[6:]),
+	}
+	if len(b) >= AckSize+AckLatencySize {
+		l := b[AckSize:]
+		ack.Latency = AckLatency{
+			Timestamp: int64(binary.BigEndian.Uint64(l[0:8])),
+			Parse:     time.Duration(binary.BigEndian.Uint32(l[8:12])) * time.Microsecond,
+			Queue:     time.Duration(binary.BigEndian.Uint32(l[12:16])) * time.Microsecond,
+			Apply:     time.Duration(binary.BigEndian.Uint32(l[16:20])) * time.Microsecond,
+		}
+	}
+
+	return &ack, nil
+}
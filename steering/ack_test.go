@@ -0,0 +1,61 @@
+package steering
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+//BenchmarkAckPut will encode an acknowledgement with latency extension into a reused buffer
+func BenchmarkAckPut(b *testing.B) {
+	b.ReportAllocs()
+	var buffer [AckSize + AckLatencySize]byte
+	ack := Ack{Sequence: 1, Latency: AckLatency{Timestamp: 1}}
+	for i := 0; i < b.N; i++ {
+		ack.Put(buffer[:])
+	}
+}
+
+//TestAckRoundTrip will encode and parse acknowledgements with and without the latency extension
+func TestAckRoundTrip(t *testing.T) {
+	step := Step{Speed: 20, CarMovement: HMovementLeft, CarMovementPercentage: 50, CameraVMovement: VMovementUp, CameraVPercentage: 5}
+	tests := []struct {
+		name    string
+		ack     Ack
+		size    int
+		latency AckLatency
+	}{
+		{"plain", Ack{Sequence: 3, Error: ErrorMove, Flags: StatusCalibrated | StatusError, Step: step}, AckSize, AckLatency{}},
+		{"latency", Ack{Sequence: 4, Flags: StatusMoving, Step: step, Latency: AckLatency{Timestamp: 1234567890123,
+			Parse: 1500 * time.Nanosecond, Queue: 2 * time.Millisecond, Apply: 3*time.Millisecond + 999*time.Nanosecond}},
+			AckSize + AckLatencySize, AckLatency{Timestamp: 1234567890123, Parse: time.Microsecond, Queue: 2 * time.Millisecond,
+				Apply: 3 * time.Millisecond}},
+	}
+
+	for _, test := range tests {
+		var buffer [AckSize + AckLatencySize]byte
+		n := test.ack.Put(buffer[:])
+		if n != test.size {
+			t.Errorf("%v: put %v bytes, expected: %v", test.name, n, test.size)
+		}
+		if !bytes.Equal(test.ack.Bytes(), buffer[:n]) {
+			t.Errorf("%v: Bytes and Put differ", test.name)
+		}
+
+		ack, err := ParseAck(buffer[:n])
+		if err != nil {
+			t.Errorf("%v: could not parse acknowledgement. Error: %v", test.name, err)
+			continue
+		}
+		expected := test.ack
+		expected.Latency = test.latency
+		if *ack != expected {
+			t.Errorf("%v: parsed %+v, expected: %+v", test.name, *ack, expected)
+		}
+	}
+
+	_, err := ParseAck(make([]byte, AckSize-1))
+	if err == nil {
+		t.Error("Parsing a short acknowledgement did not fail")
+	}
+}
@@ -0,0 +1,165 @@
+package steering
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const arbiterWatchdogInterval = 100 * time.Millisecond
+
+//ErrOverridden is returned to an engine whose step was ignored because a source with a higher priority is in control
+var ErrOverridden = errors.New("Step was overridden by a source with a higher priority")
+
+//ArbiterSource is an engine registered at an arbiter. A source is alive once it sent a step and stays alive until no
+//step was received within Timeout (a Timeout of zero keeps the source alive forever)
+type ArbiterSource struct {
+	Name     string
+	Engine   Engine
+	Priority int
+	Timeout  time.Duration
+}
+
+//Arbiter runs several engines at once. The steps of the alive source with the highest priority are passed to the car,
+//all other steps are rejected with ErrOverridden. OnHandover is called whenever the controlling source changes
+type Arbiter struct {
+	Lifecycle
+	Sources    []ArbiterSource
+	OnHandover func(from string, to string)
+
+	//mutex guards last and current only. The status of the engine is guarded by the mutex of the embedded Lifecycle,
+	//both are never held at the same time
+	mutex   sync.Mutex
+	last    []time.Time
+	current int
+}
+
+//Start will start all registered engines. If one source fails all sources are stopped
+func (a *Arbiter) Start(ctx context.Context, sc StepCallback) error {
+	ctx, err := a.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	a.last = make([]time.Time, len(a.Sources))
+	a.current = -1
+	a.mutex.Unlock()
+
+	engines := make([]Engine, len(a.Sources))
+	names := make([]string, len(a.Sources))
+	for i, source := range a.Sources {
+		engines[i] = source.Engine
+		names[i] = source.Name
+	}
+	err = doStartAll(ctx, engines, names, func(index int) StepCallback {
+		return func(step *Step) error {
+			return a.doHandle(index, step, sc)
+		}
+	})
+	if err != nil {
+		return a.Abort(err)
+	}
+
+	a.Go(ctx, func(ctx context.Context) error {
+		go a.doWatch(ctx, sc)
+		return doRunAll(ctx, &a.Lifecycle, engines, names)
+	})
+
+	return nil
+}
+
+func (a *Arbiter) doAlive(index int, now time.Time) bool {
+	source := a.Sources[index]
+	if a.last[index].IsZero() {
+		return false
+	}
+
+	return source.Timeout <= 0 || now.Sub(a.last[index]) <= source.Timeout
+}
+
+//doElect will return the alive source with the highest priority or -1 (must be called with a locked mutex)
+func (a *Arbiter) doElect(now time.Time) int {
+	winner := -1
+	for i := range a.Sources {
+		if !a.doAlive(i, now) {
+			continue
+		}
+		if winner < 0 || a.Sources[i].Priority > a.Sources[winner].Priority {
+			winner = i
+		}
+	}
+
+	return winner
+}
+
+//doHandover will change the controlling source (must be called with a locked mutex)
+func (a *Arbiter) doHandover(to int) {
+	if to == a.current {
+		return
+	}
+
+	from := a.doName(a.current)
+	a.current = to
+	log.Printf("Steering handed over from: %v to: %v\n", from, a.doName(to))
+	if a.OnHandover != nil {
+		go a.OnHandover(from, a.doName(to))
+	}
+}
+
+func (a *Arbiter) doName(index int) string {
+	if index < 0 {
+		return "nobody"
+	}
+
+	return a.Sources[index].Name
+}
+
+func (a *Arbiter) doHandle(index int, step *Step, sc StepCallback) error {
+	now := time.Now()
+
+	a.mutex.Lock()
+	a.last[index] = now
+	winner := a.doElect(now)
+	a.doHandover(winner)
+	a.mutex.Unlock()
+
+	if winner != index {
+		return ErrOverridden
+	}
+
+	return sc(step)
+}
+
+func (a *Arbiter) doWatch(ctx context.Context, sc StepCallback) {
+	ticker := time.NewTicker(arbiterWatchdogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			a.mutex.Lock()
+			previous := a.current
+			winner := a.doElect(now)
+			a.doHandover(winner)
+			a.mutex.Unlock()
+
+			if previous >= 0 && winner < 0 {
+				log.Printf("All steering sources timed out. Will stop the car\n")
+				sc(&Step{})
+			}
+		}
+	}
+}
+
+//Current will return the name of the source which is currently in control (or "nobody")
+func (a *Arbiter) Current() string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.doName(a.current)
+}
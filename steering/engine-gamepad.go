@@ -0,0 +1,80 @@
+package steering
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+//GamepadEngine will steer the car with a gamepad connected to a /dev/input/event* (evdev) or /dev/input/js* device
+type GamepadEngine struct {
+	Lifecycle
+	Device  string
+	Mapping GamepadMapping
+}
+
+//NewGamepadEngine will create a gamepad engine for a device with the default mapping of the device type
+func NewGamepadEngine(device string) *GamepadEngine {
+	mapping := DefaultEvdevMapping
+	if doIsJoystickDevice(device) {
+		mapping = DefaultJoystickMapping
+	}
+
+	return &GamepadEngine{Device: device, Mapping: mapping}
+}
+
+func doIsJoystickDevice(device string) bool {
+	return strings.HasPrefix(device[strings.LastIndex(device, "/")+1:], "js")
+}
+
+//Start will open the device and translate all events into steps. The car is stopped when the device is lost
+func (s *GamepadEngine) Start(ctx context.Context, sc StepCallback) error {
+	ctx, err := s.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	device, err := os.Open(s.Device)
+	if err != nil {
+		return s.Abort(fmt.Errorf("Could not open gamepad device: %v. Error: %v", s.Device, err))
+	}
+
+	var decoder GamepadDecoder
+	if doIsJoystickDevice(s.Device) {
+		decoder = &JoystickDecoder{Reader: device}
+	} else {
+		decoder = &EvdevDecoder{Reader: device, TimeSize: EvdevTimeSize}
+	}
+
+	log.Printf("Listening for gamepad events on: %v\n", s.Device)
+	s.Go(ctx, func(ctx context.Context) error {
+		go func() {
+			<-ctx.Done()
+			device.Close()
+		}()
+		defer sc(&Step{})
+
+		state := GamepadState{Mapping: s.Mapping}
+		for {
+			event, err := decoder.Next()
+			if err == io.EOF || ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("Stopped reading gamepad events from: %v. Reason: %v", s.Device, err)
+			}
+
+			if state.Apply(event) {
+				err = sc(state.Step())
+				if err != nil {
+					s.Report(fmt.Errorf("Could not apply step from gamepad. Error: %v", err))
+				}
+			}
+		}
+	})
+
+	return nil
+}
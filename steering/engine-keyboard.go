@@ -0,0 +1,88 @@
+package steering
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+
+	"sdmimaye.de/smart-video-car/stream"
+)
+
+//KeyboardEngine will steer the car with key presses from a stream: W/S or arrow up/down for the speed, A/D or arrow left/right
+//for the steering, I/K and J/L for the camera, space to stop, C to center the camera and Q to leave
+type KeyboardEngine struct {
+	Lifecycle
+	Stream stream.Stream
+	State  StateCallback
+}
+
+//NewKeyboardEngine will create a new keyboard engine reading from the passed stream
+func NewKeyboardEngine(s stream.Stream) *KeyboardEngine {
+	return &KeyboardEngine{Stream: s}
+}
+
+//Start will switch the stream into raw mode and start reading key presses. The engine stops once the driver left,
+//after it was canceled it stops with the next key press
+func (s *KeyboardEngine) Start(ctx context.Context, sc StepCallback) error {
+	ctx, err := s.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	raw, ok := s.Stream.(stream.RawStream)
+	if ok {
+		err := raw.SetRawMode(true)
+		if err != nil {
+			return s.Abort(fmt.Errorf("Could not start Keyboard-Steering Engine. Error: %v", err))
+		}
+	}
+
+	w := s.Stream.GetWriter()
+	fmt.Fprint(w, "W/S: Speed, A/D: Steering, I/K/J/L: Camera, Space: Stop, C: Center camera, Q: Quit\r\n")
+	s.Go(ctx, func(ctx context.Context) error {
+		if ok {
+			defer raw.SetRawMode(false)
+		}
+		defer fmt.Fprint(w, "\r\n")
+		defer sc(&Step{})
+
+		decoder := KeyDecoder{Reader: bufio.NewReader(s.Stream.GetReader())}
+		var state KeyboardState
+		s.doPrintStatus(w, &state)
+		for ctx.Err() == nil {
+			key, err := decoder.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("Stopped reading keys. Reason: %v", err)
+			}
+			if !state.Apply(key) {
+				return nil
+			}
+
+			err = sc(state.Step())
+			if err != nil {
+				fmt.Fprintf(w, "\r\nCould not steer car. Error: %v\r\n", err)
+			}
+			s.doPrintStatus(w, &state)
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+func (s *KeyboardEngine) doPrintStatus(w io.Writer, state *KeyboardState) {
+	status := ""
+	if s.State != nil && s.State().Flags&StatusError != 0 {
+		status = " | ERROR"
+	}
+
+	fmt.Fprintf(w, "\r%v%v\x1b[K", state, status)
+}
+
+//Interactive marks the keyboard engine as interactive
+func (s *KeyboardEngine) Interactive() {}
@@ -0,0 +1,184 @@
+package steering
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"sdmimaye.de/smart-video-car/stream"
+)
+
+//MissionEngine will drive a mission script. While the mission is running it can be paused with [p], resumed with [r]
+//and aborted with [a] over the stream. A dry-run will only print the timeline of the mission without moving the car
+type MissionEngine struct {
+	Lifecycle
+	Stream  stream.Stream
+	Mission *Mission
+	DryRun  bool
+}
+
+type missionControl int
+
+const (
+	missionPause missionControl = iota
+	missionResume
+	missionAbort
+)
+
+type missionDriver struct {
+	sc       StepCallback
+	control  chan missionControl
+	w        io.Writer
+	state    MissionState
+	finished chan struct{}
+}
+
+func (d *missionDriver) Apply(state MissionState) error {
+	d.state = state
+	return d.sc(state.Step())
+}
+
+func (d *missionDriver) Wait(duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	started := time.Now()
+
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case c := <-d.control:
+			switch c {
+			case missionAbort:
+				return false
+			case missionPause:
+				remaining := duration - time.Since(started)
+				timer.Stop()
+				d.sc(MissionState{Pan: d.state.Pan, Tilt: d.state.Tilt}.Step())
+				fmt.Fprint(d.w, "Mission paused. [r] Resume, [a] Abort\r\n")
+				if !d.doWaitForResume() {
+					return false
+				}
+				fmt.Fprint(d.w, "Mission resumed\r\n")
+				d.sc(d.state.Step())
+				duration = remaining
+				started = time.Now()
+				timer.Reset(remaining)
+			}
+		}
+	}
+}
+
+func (d *missionDriver) doWaitForResume() bool {
+	for c := range d.control {
+		switch c {
+		case missionResume:
+			return true
+		case missionAbort:
+			return false
+		}
+	}
+
+	return false
+}
+
+type missionTimeline struct {
+	w      io.Writer
+	offset time.Duration
+}
+
+func (t *missionTimeline) Apply(state MissionState) error {
+	fmt.Fprintf(t.w, "%8.3fs Speed: %4.0f | Steering: %4.0f | Camera Pan: %4.0f | Camera Tilt: %4.0f\r\n", t.offset.Seconds(), state.Speed, state.Steering, state.Pan, state.Tilt)
+	return nil
+}
+
+func (t *missionTimeline) Wait(duration time.Duration) bool {
+	t.offset += duration
+	return true
+}
+
+//Start will start the mission (or print its timeline for a dry-run) and listen for control commands on the stream. The
+//engine stops once the mission was finished or aborted, a canceled mission is aborted
+func (s *MissionEngine) Start(ctx context.Context, sc StepCallback) error {
+	ctx, err := s.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := s.Stream.GetWriter()
+	if s.DryRun {
+		s.Go(ctx, func(ctx context.Context) error {
+			timeline := missionTimeline{w: w}
+			err := s.Mission.Run(&timeline, MissionDryRunLimit)
+			if err != nil {
+				fmt.Fprintf(w, "Dry-run stopped. Reason: %v\r\n", err)
+			}
+			fmt.Fprintf(w, "Mission would take: %v\r\n", timeline.offset)
+			return nil
+		})
+		return nil
+	}
+
+	driver := missionDriver{sc: sc, w: w, control: make(chan missionControl), finished: make(chan struct{})}
+	go func() {
+		defer close(driver.finished)
+		err := s.Mission.Run(&driver, 0)
+		if err != nil {
+			fmt.Fprintf(w, "Mission stopped. Reason: %v\r\n", err)
+			return
+		}
+		fmt.Fprint(w, "Mission finished. Press enter to continue...\r\n")
+	}()
+
+	s.Go(ctx, func(ctx context.Context) error {
+		go func() {
+			select {
+			case <-ctx.Done():
+				s.doSend(&driver, missionAbort)
+			case <-driver.finished:
+			}
+		}()
+
+		fmt.Fprint(w, "Mission started. [p] Pause, [r] Resume, [a] Abort\r\n")
+		reader := bufio.NewReader(s.Stream.GetReader())
+		for {
+			command, err := reader.ReadString('\n')
+			select {
+			case <-driver.finished:
+				return nil
+			default:
+			}
+			if err != nil {
+				s.doSend(&driver, missionAbort)
+				<-driver.finished
+				return fmt.Errorf("Stopped reading mission commands. Reason: %v", err)
+			}
+
+			if strings.HasPrefix(command, "p") {
+				s.doSend(&driver, missionPause)
+			} else if strings.HasPrefix(command, "r") {
+				s.doSend(&driver, missionResume)
+			} else if strings.HasPrefix(command, "a") {
+				s.doSend(&driver, missionAbort)
+				<-driver.finished
+				fmt.Fprint(w, "Mission aborted\r\n")
+				return nil
+			}
+		}
+	})
+
+	return nil
+}
+
+func (s *MissionEngine) doSend(driver *missionDriver, c missionControl) {
+	select {
+	case driver.control <- c:
+	case <-driver.finished:
+	}
+}
+
+//Interactive marks the mission engine as interactive
+func (s *MissionEngine) Interactive() {}
@@ -0,0 +1,184 @@
+package steering
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	mqttQoS          = 1
+	mqttStateTimeout = 5 * time.Second
+)
+
+//MQTTEngine will steer the car with JSON steps received on the command topic and publish the car state to retained topics.
+//All topics are placed below Topic: <Topic>/command, <Topic>/state, <Topic>/failsafe, <Topic>/calibration and <Topic>/online.
+//The online topic is set to "false" by the last will of the connection. When Failsafe is set, the car will be stopped
+//if no command was received in time
+type MQTTEngine struct {
+	Lifecycle
+	Broker        string
+	ClientID      string
+	Topic         string
+	CommandTopic  string
+	StateInterval time.Duration
+	Failsafe      time.Duration
+	State         StateCallback
+
+	client   mqtt.Client
+	mutex    sync.Mutex
+	last     time.Time
+	tripped  bool
+	callback StepCallback
+}
+
+type mqttFailsafeEvent struct {
+	Active bool      `json:"active"`
+	Time   time.Time `json:"time"`
+}
+
+type mqttCalibrationStatus struct {
+	Calibrated bool `json:"calibrated"`
+}
+
+//Start will connect to the broker, subscribe to the command topic and start publishing the car state
+func (s *MQTTEngine) Start(ctx context.Context, sc StepCallback) error {
+	ctx, err := s.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	if s.CommandTopic == "" {
+		s.CommandTopic = s.Topic + "/command"
+	}
+	if s.StateInterval <= 0 {
+		s.StateInterval = time.Second
+	}
+
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(s.Broker)
+	opts.SetClientID(s.ClientID)
+	opts.SetWill(s.Topic+"/online", "false", mqttQoS, true)
+	opts.SetAutoReconnect(true)
+	opts.SetOnConnectHandler(func(client mqtt.Client) {
+		log.Printf("Connected to MQTT broker: %v\n", s.Broker)
+		client.Publish(s.Topic+"/online", mqttQoS, true, "true")
+		token := client.Subscribe(s.CommandTopic, mqttQoS, s.doHandleCommand)
+		if token.WaitTimeout(mqttStateTimeout) && token.Error() != nil {
+			s.Report(fmt.Errorf("Could not subscribe to MQTT topic: %v. Error: %v", s.CommandTopic, token.Error()))
+		}
+	})
+	opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
+		s.Report(fmt.Errorf("Lost connection to MQTT broker: %v. Error: %v", s.Broker, err))
+	})
+
+	s.callback = sc
+	s.last = time.Now()
+	s.tripped = false
+	s.client = mqtt.NewClient(opts)
+	token := s.client.Connect()
+	if !token.WaitTimeout(mqttStateTimeout) || token.Error() != nil {
+		s.client.Disconnect(0)
+		return s.Abort(fmt.Errorf("Could not connect to MQTT broker: %v. Error: %v", s.Broker, token.Error()))
+	}
+
+	log.Printf("Listening for incomming MQTT instructions on topic: %v\n", s.CommandTopic)
+	s.Go(ctx, s.doRun)
+
+	return nil
+}
+
+func (s *MQTTEngine) doHandleCommand(client mqtt.Client, msg mqtt.Message) {
+	var step Step
+	err := json.Unmarshal(msg.Payload(), &step)
+	if err != nil {
+		s.Report(fmt.Errorf("Could not parse MQTT command on topic: %v. Error: %v", msg.Topic(), err))
+		return
+	}
+
+	s.mutex.Lock()
+	s.last = time.Now()
+	tripped := s.tripped
+	s.tripped = false
+	s.mutex.Unlock()
+	if tripped {
+		s.doPublish("failsafe", mqttFailsafeEvent{Active: false, Time: time.Now()})
+	}
+
+	err = s.callback(&step)
+	if err != nil {
+		s.Report(fmt.Errorf("Could not apply step from MQTT. Error: %v", err))
+	}
+	s.doPublishState()
+}
+
+//doRun will publish the car state until the engine is stopped and then publish the offline state and disconnect
+func (s *MQTTEngine) doRun(ctx context.Context) error {
+	ticker := time.NewTicker(s.StateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			token := s.client.Publish(s.Topic+"/online", mqttQoS, true, "false")
+			token.WaitTimeout(mqttStateTimeout)
+			s.client.Disconnect(250)
+			if token.Error() != nil {
+				return fmt.Errorf("Error while publishing offline state: %v", token.Error())
+			}
+			return nil
+		case <-ticker.C:
+			s.doCheckFailsafe()
+			s.doPublishState()
+		}
+	}
+}
+
+func (s *MQTTEngine) doCheckFailsafe() {
+	if s.Failsafe <= 0 || s.State == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	expired := !s.tripped && time.Since(s.last) > s.Failsafe
+	if expired {
+		s.tripped = true
+	}
+	s.mutex.Unlock()
+	if !expired {
+		return
+	}
+
+	log.Printf("No MQTT command received for %v. Failsafe will stop the car\n", s.Failsafe)
+	step := s.State().Step
+	step.Speed = 0
+	err := s.callback(&step)
+	if err != nil {
+		log.Printf("Could not stop car after failsafe. Error: %v\n", err)
+	}
+	s.doPublish("failsafe", mqttFailsafeEvent{Active: true, Time: time.Now()})
+}
+
+func (s *MQTTEngine) doPublishState() {
+	if s.State == nil {
+		return
+	}
+
+	state := s.State()
+	s.doPublish("state", state)
+	s.doPublish("calibration", mqttCalibrationStatus{Calibrated: state.Flags&StatusCalibrated != 0})
+}
+
+func (s *MQTTEngine) doPublish(topic string, v interface{}) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Could not encode MQTT payload for topic: %v. Error: %v\n", topic, err)
+		return
+	}
+
+	s.client.Publish(s.Topic+"/"+topic, mqttQoS, true, payload)
+}
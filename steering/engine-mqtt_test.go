@@ -0,0 +1,158 @@
+package steering
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+//doStartBroker will start an in-process MQTT broker on the loopback interface and return it with its address
+func doStartBroker(t *testing.T) (*mochi.Server, string) {
+	broker := mochi.New(&mochi.Options{InlineClient: true, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	err := broker.AddHook(new(auth.AllowHook), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	err = broker.AddListener(listener)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = broker.Serve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { broker.Close() })
+
+	return broker, "tcp://" + listener.Address()
+}
+
+//doSubscribe will pass the payloads of all messages published on a topic into the returned channel
+func doSubscribe(t *testing.T, broker *mochi.Server, topic string, id int) chan string {
+	payloads := make(chan string, 64)
+	err := broker.Subscribe(topic, id, func(cl *mochi.Client, sub packets.Subscription, pk packets.Packet) {
+		payloads <- string(pk.Payload)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return payloads
+}
+
+//doRetained will return the retained payload of a topic or an empty string
+func doRetained(broker *mochi.Server, topic string) string {
+	for _, pk := range broker.Topics.Messages(topic) {
+		return string(pk.Payload)
+	}
+
+	return ""
+}
+
+//doAwait will wait for a payload which satisfies the condition and fail the test after a few seconds
+func doAwait(t *testing.T, payloads chan string, condition func(string) bool) string {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case payload := <-payloads:
+			if condition(payload) {
+				return payload
+			}
+		case <-timeout:
+			t.Fatal("Timeout while waiting for MQTT message")
+			return ""
+		}
+	}
+}
+
+//TestMQTTEngine will steer the car through an in-process broker and check the retained state, the failsafe and the
+//online state set by the engine and its last will
+func TestMQTTEngine(t *testing.T) {
+	broker, address := doStartBroker(t)
+	online := doSubscribe(t, broker, "car/online", 1)
+	failsafe := doSubscribe(t, broker, "car/failsafe", 2)
+
+	state := State{Step: Step{Speed: 20, CarMovement: HMovementRight, CarMovementPercentage: 40}, Flags: StatusCalibrated}
+	steps := make(chan Step, 64)
+	engine := MQTTEngine{Broker: address, ClientID: "test-car", Topic: "car", StateInterval: 20 * time.Millisecond,
+		Failsafe: 300 * time.Millisecond, State: func() State { return state }}
+	err := engine.Start(context.Background(), func(step *Step) error {
+		steps <- *step
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Stop()
+
+	doAwait(t, online, func(p string) bool { return p == "true" })
+	//the subscription of the command topic is acknowledged after the online state, so repeat the command until it arrived
+	var step Step
+	for deadline := time.Now().Add(5 * time.Second); step.Speed != 50; {
+		if time.Now().After(deadline) {
+			t.Fatal("Command was not applied")
+		}
+		broker.Publish("car/command", []byte(`{"speed":50,"cameraVMovement":1,"cameraVPercentage":10}`), false, 1)
+		select {
+		case step = <-steps:
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	if step != (Step{Speed: 50, CameraVMovement: VMovementUp, CameraVPercentage: 10}) {
+		t.Errorf("Applied step: %+v", step)
+	}
+
+	broker.Publish("car/command", []byte(`{"speed":`), false, 1)
+	select {
+	case err := <-engine.Errors():
+		if err == nil {
+			t.Error("Expected a parse error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Invalid command was not reported")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	var published State
+	err = json.Unmarshal([]byte(doRetained(broker, "car/state")), &published)
+	if err != nil || published != state {
+		t.Errorf("Retained state: %v, expected: %+v. Error: %v", doRetained(broker, "car/state"), state, err)
+	}
+	if calibration := doRetained(broker, "car/calibration"); calibration != `{"calibrated":true}` {
+		t.Errorf("Retained calibration: %v", calibration)
+	}
+
+	doAwait(t, failsafe, func(p string) bool {
+		var event mqttFailsafeEvent
+		return json.Unmarshal([]byte(p), &event) == nil && event.Active
+	})
+	for stop := range steps {
+		if stop.Speed == 0 {
+			if stop.CarMovement != HMovementRight || stop.CarMovementPercentage != 40 {
+				t.Errorf("Failsafe step: %+v, expected the last state without speed", stop)
+			}
+			break
+		}
+	}
+
+	client, ok := broker.Clients.Get("test-car")
+	if !ok {
+		t.Fatal("Engine is not connected")
+	}
+	client.Net.Conn.Close()
+	doAwait(t, online, func(p string) bool { return p == "false" })
+
+	doAwait(t, online, func(p string) bool { return p == "true" })
+	engine.Stop()
+	if retained := doRetained(broker, "car/online"); retained != "false" {
+		t.Errorf("Retained online state after stop: %v", retained)
+	}
+}
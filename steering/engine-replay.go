@@ -0,0 +1,71 @@
+package steering
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+//ReplayEngine will feed a recording back to the car with the original timing. Scale speeds up (>1) or slows down (<1)
+//the replay. From and To select a time window of the recording (a To of zero replays until the end)
+type ReplayEngine struct {
+	Lifecycle
+	Path  string
+	Scale float64
+	From  time.Duration
+	To    time.Duration
+}
+
+//Start will load the recording and start the replay. The engine stops by itself at the end of the recording
+func (s *ReplayEngine) Start(ctx context.Context, sc StepCallback) error {
+	ctx, err := s.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	steps, err := LoadRecording(s.Path)
+	if err != nil {
+		return s.Abort(fmt.Errorf("Could not start Replay-Steering Engine. Error: %v", err))
+	}
+	scale := s.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+
+	var window []RecordedStep
+	for _, step := range steps {
+		if step.Offset >= s.From && (s.To <= 0 || step.Offset <= s.To) {
+			window = append(window, step)
+		}
+	}
+	if len(window) == 0 {
+		return s.Abort(fmt.Errorf("Recording %v contains no steps between %v and %v", s.Path, s.From, s.To))
+	}
+
+	log.Printf("Replaying %v steps from: %v\n", len(window), s.Path)
+	s.Go(ctx, func(ctx context.Context) error {
+		started := time.Now()
+		first := window[0].Offset
+		for i := range window {
+			due := time.Duration(float64(window[i].Offset-first) / scale)
+			select {
+			case <-ctx.Done():
+				sc(&Step{})
+				return nil
+			case <-time.After(due - time.Since(started)):
+			}
+
+			step := window[i].Step
+			err := sc(&step)
+			if err != nil {
+				s.Report(fmt.Errorf("Could not apply replayed step: %v. Error: %v", i, err))
+			}
+		}
+		sc(&Step{})
+		log.Printf("Replay of %v finished\n", s.Path)
+		return nil
+	})
+
+	return nil
+}
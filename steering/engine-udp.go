@@ -1,64 +1,137 @@
 package steering
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
+	"net/netip"
+	"time"
+
+	"sdmimaye.de/smart-video-car/latency"
+	"sdmimaye.de/smart-video-car/network"
 )
 
-//UDPEngine will steer the car over an udp socket
+//DefaultUDPAddress is the address the UDP engine will listen on if no address is configured
+const DefaultUDPAddress = ":1338"
+
+//UDPEngine will steer the car over an udp socket. Every AckEvery-th command will be answered with an Ack to the sender,
+//commands which could not be applied are always answered. An AckEvery of zero disables all acknowledgements.
+//Address is the listen address (see network.ResolveAddress)
 type UDPEngine struct {
-	socket *net.UDPConn
+	Lifecycle
+	Address  string
+	AckEvery int
+	State    StateCallback
 }
 
-//StartEngine will start the UDP socket and wait for incomming commands
-func (s UDPEngine) StartEngine(sc StepCallback) error {
-	if s.socket != nil {
-		log.Println("UDP-Steering Engine is already running...")
-		return nil
+//Start will open the UDP socket and wait for incomming commands until the engine is stopped
+func (s *UDPEngine) Start(ctx context.Context, sc StepCallback) error {
+	ctx, err := s.Begin(ctx)
+	if err != nil {
+		return err
 	}
 
-	addr := net.UDPAddr{Port: 1338, IP: net.ParseIP("0.0.0.0")}
-	socket, err := net.ListenUDP("udp", &addr)
+	address := s.Address
+	if address == "" {
+		address = DefaultUDPAddress
+	}
+	resolved, err := network.ResolveAddress(address)
 	if err != nil {
-		return fmt.Errorf("Could not start UDP-Steering Engine. Error: %v", err)
+		return s.Abort(fmt.Errorf("Could not start UDP-Steering Engine. Error: %v", err))
 	}
+	addr, err := net.ResolveUDPAddr("udp", resolved)
+	if err != nil {
+		return s.Abort(fmt.Errorf("Could not start UDP-Steering Engine on: %v. Error: %v", address, err))
+	}
+	socket, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return s.Abort(fmt.Errorf("Could not start UDP-Steering Engine. Error: %v", err))
+	}
+
+	log.Printf("Listening for incomming UDP instructions on: %v\n", socket.LocalAddr())
+	s.Go(ctx, func(ctx context.Context) error {
+		go func() {
+			<-ctx.Done()
+			socket.Close()
+		}()
 
-	log.Printf("Listening for incomming UDP instructions on Port: %v\n", addr.Port)
-	go func() {
-		var buffer [64]byte
-		for socket != nil {
-			cnt, remote, err := socket.ReadFromUDP(buffer[:])
-			if err == nil {
-				log.Printf("UDP Message received: Sender: %v, Length: %v, Data: %v", remote, cnt, buffer[:cnt])
-				step, err := ParseStep(buffer[:cnt])
-				if err != nil {
-					log.Printf("Error while handling command: %v\n", err)
-				} else {
-					sc(step)
+		var receiver udpReceiver
+		for {
+			cnt, remote, err := socket.ReadFromUDPAddrPort(receiver.packet[:])
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
 				}
-			} else {
-				log.Printf("Error while receving UDP Commands: %v\n", err)
-				s.EndEngine()
+				return fmt.Errorf("Error while receving UDP Commands: %v", err)
+			}
+
+			if Verbose {
+				log.Printf("UDP Message received: Sender: %v, Length: %v, Data: %v", remote, cnt, receiver.packet[:cnt])
+			}
+			err = receiver.doHandle(cnt, time.Now(), sc)
+			if err != nil {
+				s.Report(fmt.Errorf("Could not parse UDP command from: %v. Error: %v", remote, err))
 			}
+			s.doAcknowledge(socket, remote, &receiver)
 		}
-	}()
+	})
 
 	return nil
 }
 
-//EndEngine will terminate the socket
-func (s UDPEngine) EndEngine() error {
-	if s.socket == nil {
-		log.Println("UDP Steering Engine has ended already...")
-		return nil
-	}
+//udpReceiver contains the buffers which are reused for every received command, so that the receive loop does not allocate
+type udpReceiver struct {
+	packet   [64]byte
+	reply    [AckSize + AckLatencySize]byte
+	step     Step
+	ack      Ack
+	received uint32
+}
 
-	err := s.socket.Close()
-	s.socket = nil
+//doHandle will decode the received packet into the reused step, pass it to the callback and prepare the acknowledgement.
+//The returned error is the parse error of the packet
+func (r *udpReceiver) doHandle(cnt int, arrived time.Time, sc StepCallback) error {
+	r.received++
+	r.ack = Ack{Sequence: r.received}
+	err := DecodeStep(r.packet[:cnt], &r.step)
 	if err != nil {
-		return fmt.Errorf("Error while closing udp steering socket: %v", err)
+		r.ack.Error = ErrorParse
+		return err
 	}
 
+	parsed := time.Since(arrived)
+	latency.Parse.Observe(parsed)
+	if r.step.Timestamp != 0 {
+		latency.Network.Observe(arrived.Sub(time.Unix(0, r.step.Timestamp)))
+		//the step was not queued yet, so queue and apply belong to the previously applied step
+		r.ack.Latency = AckLatency{Timestamp: r.step.Timestamp, Parse: parsed, Queue: latency.Queue.Last(), Apply: latency.Apply.Last()}
+	}
+	if r.step.Sequence != 0 {
+		r.ack.Sequence = r.step.Sequence
+	}
+	//validation errors belong to this step, apply errors (ErrorMove) to a previously applied one (see Ack)
+	r.ack.Error = ErrorCodeOf(sc(&r.step))
 	return nil
 }
+
+func (s *UDPEngine) doAcknowledge(socket *net.UDPConn, remote netip.AddrPort, r *udpReceiver) {
+	if s.AckEvery <= 0 {
+		return
+	}
+	if r.ack.Error == ErrorNone && r.received%uint32(s.AckEvery) != 0 {
+		return
+	}
+
+	if s.State != nil {
+		state := s.State()
+		r.ack.Flags = state.Flags
+		r.ack.Step = state.Step
+	}
+
+	n := r.ack.Put(r.reply[:])
+	_, err := socket.WriteToUDPAddrPort(r.reply[:n], remote)
+	if err != nil {
+		s.Report(fmt.Errorf("Could not send acknowledgement to: %v. Error: %v", remote, err))
+	}
+}
@@ -0,0 +1,130 @@
+package steering
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+//BenchmarkUDPRoundTrip will send steps to a UDP engine on the loopback interface and wait for every acknowledgement
+func BenchmarkUDPRoundTrip(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	address := listener.LocalAddr().String()
+	listener.Close()
+
+	engine := UDPEngine{Address: address, AckEvery: 1}
+	err = engine.Start(context.Background(), func(*Step) error { return nil })
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer engine.Stop()
+
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+
+	b.ReportAllocs()
+	var reply [AckSize + AckLatencySize]byte
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err = conn.Write(benchmarkPacket)
+		if err == nil {
+			_, err = conn.Read(reply[:])
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//TestUDPAckEvery will check that only every AckEvery-th command is acknowledged unless it failed
+func TestUDPAckEvery(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.LocalAddr().String()
+	listener.Close()
+
+	failure := errors.New("Could not move")
+	engine := UDPEngine{Address: address, AckEvery: 3}
+	err = engine.Start(context.Background(), func(step *Step) error {
+		if step.Speed < 0 {
+			return failure
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Stop()
+
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	packets := [][]byte{
+		EncodeStep(&Step{Speed: 10, Sequence: 11}),
+		EncodeStep(&Step{Speed: -10, Sequence: 12}),
+		EncodeStep(&Step{Speed: 10, Sequence: 13}),
+		make([]byte, StepSize-1),
+		EncodeStep(&Step{Speed: 10, Sequence: 15}),
+		EncodeStep(&Step{Speed: 10, Sequence: 16}),
+	}
+	expected := []struct {
+		sequence uint32
+		code     ErrorCode
+	}{
+		{12, ErrorMove},
+		{13, ErrorNone},
+		{4, ErrorParse},
+		{16, ErrorNone},
+	}
+	for _, packet := range packets {
+		_, err = conn.Write(packet)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var reply [AckSize + AckLatencySize]byte
+	for _, e := range expected {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := conn.Read(reply[:])
+		if err != nil {
+			t.Fatalf("Missing acknowledgement of: %v. Error: %v", e.sequence, err)
+		}
+		ack, err := ParseAck(reply[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ack.Sequence != e.sequence || ack.Error != e.code {
+			t.Errorf("Acknowledged: %v with error: %v, expected: %v with error: %v", ack.Sequence, ack.Error, e.sequence, e.code)
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	n, err := conn.Read(reply[:])
+	if err == nil {
+		ack, _ := ParseAck(reply[:n])
+		t.Errorf("Unexpected acknowledgement: %+v", ack)
+	}
+}
@@ -0,0 +1,173 @@
+package steering
+
+import (
+	"context"
+	_ "embed" //Required to embed the joystick page
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"sdmimaye.de/smart-video-car/network"
+)
+
+//go:embed engine-websocket.html
+var joystickPage []byte
+
+const statePushInterval = 250 * time.Millisecond
+
+//DefaultWebSocketAddress is the address the WebSocket engine will listen on if no address is configured
+const DefaultWebSocketAddress = ":8080"
+
+//WebSocketEngine will serve a browser joystick over http and steer the car with the steps received over a websocket.
+//Address is the listen address (see network.ResolveAddress). The car is stopped once the last client disconnected
+type WebSocketEngine struct {
+	Lifecycle
+	Address  string
+	State    StateCallback
+	upgrader websocket.Upgrader
+	mutex    sync.Mutex
+	conns    map[*websocket.Conn]bool
+	closing  bool
+	handlers sync.WaitGroup
+}
+
+//Start will start the http server for the joystick page and the websocket endpoint
+func (s *WebSocketEngine) Start(ctx context.Context, sc StepCallback) error {
+	ctx, err := s.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(joystickPage)
+	})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := s.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			s.Report(fmt.Errorf("Could not upgrade websocket connection from: %v. Error: %v", r.RemoteAddr, err))
+			return
+		}
+		s.doServe(conn, sc)
+	})
+
+	address := s.Address
+	if address == "" {
+		address = DefaultWebSocketAddress
+	}
+	listener, err := network.Listen("tcp", address)
+	if err != nil {
+		return s.Abort(fmt.Errorf("Could not start WebSocket-Steering Engine. Error: %v", err))
+	}
+
+	server := &http.Server{Handler: mux}
+	s.mutex.Lock()
+	s.conns = make(map[*websocket.Conn]bool)
+	s.closing = false
+	s.mutex.Unlock()
+	log.Printf("Serving browser joystick on: %v\n", listener.Addr())
+	s.Go(ctx, func(ctx context.Context) error {
+		go func() {
+			<-ctx.Done()
+			s.doClose(server)
+		}()
+
+		err := server.Serve(listener)
+		//the websocket handlers are hijacked, so the server does not wait for them
+		s.doClose(server)
+		s.handlers.Wait()
+		if err != nil && err != http.ErrServerClosed {
+			return fmt.Errorf("Error while serving WebSocket-Steering Engine: %v", err)
+		}
+		return nil
+	})
+
+	return nil
+}
+
+//doClose will reject new websocket clients, close the connections of the connected ones and close the server
+func (s *WebSocketEngine) doClose(server *http.Server) {
+	s.mutex.Lock()
+	s.closing = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+	server.Close()
+}
+
+func (s *WebSocketEngine) doServe(conn *websocket.Conn, sc StepCallback) {
+	s.mutex.Lock()
+	if s.closing {
+		s.mutex.Unlock()
+		conn.Close()
+		return
+	}
+	s.conns[conn] = true
+	s.handlers.Add(1)
+	s.mutex.Unlock()
+	log.Printf("WebSocket client connected: %v\n", conn.RemoteAddr())
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		last := len(s.conns) == 0
+		s.mutex.Unlock()
+		conn.Close()
+		if last {
+			sc(&Step{}) //stop the car, no client can steer it anymore
+		}
+		s.handlers.Done()
+	}()
+
+	var mutex sync.Mutex
+	push := func() error {
+		if s.State == nil {
+			return nil
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		return conn.WriteJSON(s.State())
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(statePushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if push() != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		var step Step
+		err := conn.ReadJSON(&step)
+		if err != nil {
+			log.Printf("WebSocket client disconnected: %v. Reason: %v\n", conn.RemoteAddr(), err)
+			return
+		}
+
+		err = sc(&step)
+		if err != nil {
+			s.Report(fmt.Errorf("Could not apply step from WebSocket client: %v. Error: %v", conn.RemoteAddr(), err))
+		}
+		err = push()
+		if err != nil {
+			log.Printf("Could not push state to WebSocket client: %v. Error: %v\n", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
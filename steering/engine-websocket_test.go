@@ -0,0 +1,141 @@
+package steering
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+//webSocketSteps records the steps passed to the step callback of a WebSocket engine
+type webSocketSteps struct {
+	mutex   sync.Mutex
+	steps   []Step
+	stopped bool
+	late    bool
+}
+
+func (w *webSocketSteps) doApply(step *Step) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.steps = append(w.steps, *step)
+	w.late = w.late || w.stopped
+
+	return nil
+}
+
+func (w *webSocketSteps) doLast() (Step, int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.steps) == 0 {
+		return Step{}, 0
+	}
+
+	return w.steps[len(w.steps)-1], len(w.steps)
+}
+
+func (w *webSocketSteps) doAwait(t *testing.T, count int) Step {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		step, n := w.doLast()
+		if n >= count {
+			return step
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Step callback was not called %v times", count)
+	return Step{}
+}
+
+func doAwaitConnections(t *testing.T, engine *WebSocketEngine, count int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		engine.mutex.Lock()
+		n := len(engine.conns)
+		engine.mutex.Unlock()
+		if n == count {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("WebSocket engine does not have %v connections", count)
+}
+
+//TestWebSocketStopsWithLastClient will check that the car is only stopped once the last client disconnected and that no
+//handler calls the step callback after Stop returned
+func TestWebSocketStopsWithLastClient(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	var steps webSocketSteps
+	engine := WebSocketEngine{Address: address}
+	err = engine.Start(context.Background(), steps.doApply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Stop()
+
+	dial := func() *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws://"+address+"/ws", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	first, second := dial(), dial()
+	defer second.Close()
+	doAwaitConnections(t, &engine, 2)
+
+	err = first.WriteJSON(Step{Speed: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps.doAwait(t, 1)
+
+	first.Close()
+	doAwaitConnections(t, &engine, 1)
+	step, n := steps.doLast()
+	if n != 1 || step.Speed != 50 {
+		t.Fatalf("Car was stopped although a client is still connected. Steps: %v, last: %+v", n, step)
+	}
+
+	second.Close()
+	step = steps.doAwait(t, 2)
+	if step != (Step{}) {
+		t.Fatalf("Car was not stopped after the last client disconnected. Last step: %+v", step)
+	}
+
+	dial()
+	doAwaitConnections(t, &engine, 1)
+	err = engine.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps.mutex.Lock()
+	steps.stopped = true
+	count := len(steps.steps)
+	steps.mutex.Unlock()
+	if count != 3 {
+		t.Fatalf("Car was not stopped before Stop returned. Steps: %v", count)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	steps.mutex.Lock()
+	defer steps.mutex.Unlock()
+	if steps.late {
+		t.Fatal("Step callback was called after Stop returned")
+	}
+}
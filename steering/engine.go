@@ -1,10 +1,25 @@
 package steering
 
-//StepCallback is a callback function which will be called when a step occured
-type StepCallback func(*Step)
+import "context"
 
-//Engine will start a new steering implementation
+//StepCallback is a callback function which will be called when a step occured. The returned error signals that the step was not applied.
+//The step is only valid during the call, engines may reuse it for the next step
+type StepCallback func(*Step) error
+
+//Engine will start a new steering implementation. Start returns once the engine is running, the engine keeps running
+//until the context is canceled, Stop is called or it fails. Wait blocks until the engine stopped and returns the error
+//it failed with. Errors delivers the errors which did not stop the engine and is closed once the engine stopped.
+//A stopped engine can be started again
 type Engine interface {
-	StartEngine(sc StepCallback) error
-	EndEngine() error
+	Start(ctx context.Context, sc StepCallback) error
+	Stop() error
+	Wait() error
+	Status() EngineStatus
+	Errors() <-chan error
+}
+
+//InteractiveEngine is an engine which takes over the stream until the driver leaves it. It stops by itself once the driver left
+type InteractiveEngine interface {
+	Engine
+	Interactive()
 }
@@ -0,0 +1,84 @@
+package steering
+
+import (
+	"context"
+	"fmt"
+)
+
+//Engines will run several steering engines side by side. All engines will call the same step callback
+type Engines struct {
+	Lifecycle
+	Engines []Engine
+}
+
+//NewEngines will combine several engines into one
+func NewEngines(engines ...Engine) *Engines {
+	return &Engines{Engines: engines}
+}
+
+//Start will start all engines. If one engine fails to start all previously started engines will be stopped. If one
+//engine fails while running all engines are stopped
+func (e *Engines) Start(ctx context.Context, sc StepCallback) error {
+	ctx, err := e.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, len(e.Engines))
+	for i := range e.Engines {
+		names[i] = fmt.Sprint(i)
+	}
+	err = doStartAll(ctx, e.Engines, names, func(int) StepCallback { return sc })
+	if err != nil {
+		return e.Abort(err)
+	}
+
+	e.Go(ctx, func(ctx context.Context) error {
+		return doRunAll(ctx, &e.Lifecycle, e.Engines, names)
+	})
+
+	return nil
+}
+
+//doStartAll will start all engines with the callback of their index and stop the already started engines on failure
+func doStartAll(ctx context.Context, engines []Engine, names []string, callback func(int) StepCallback) error {
+	for i, engine := range engines {
+		err := engine.Start(ctx, callback(i))
+		if err != nil {
+			for _, started := range engines[:i] {
+				started.Stop()
+			}
+			return fmt.Errorf("Could not start engine: %v. Error: %v", names[i], err)
+		}
+	}
+
+	return nil
+}
+
+//doRunAll will forward the errors of all engines to the lifecycle until the context is canceled or one engine failed.
+//Afterwards all engines are stopped
+func doRunAll(ctx context.Context, l *Lifecycle, engines []Engine, names []string) error {
+	failed := make(chan error, len(engines))
+	for i, engine := range engines {
+		go func(name string, engine Engine) {
+			for err := range engine.Errors() {
+				l.Report(fmt.Errorf("%v: %v", name, err))
+			}
+			err := engine.Wait()
+			if err != nil {
+				failed <- fmt.Errorf("Engine %v failed. Error: %v", name, err)
+			}
+		}(names[i], engine)
+	}
+
+	var result error
+	select {
+	case <-ctx.Done():
+	case result = <-failed:
+	}
+	for _, engine := range engines {
+		engine.Stop()
+	}
+
+	return result
+}
@@ -0,0 +1,249 @@
+package steering
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/go-ini/ini"
+)
+
+const (
+	evdevTypeKey = 0x01
+	evdevTypeAbs = 0x03
+
+	jsTypeButton = 0x01
+	jsTypeAxis   = 0x02
+	jsTypeInit   = 0x80
+	jsEventSize  = 8
+)
+
+//EvdevTimeSize is the size of the timestamp of an evdev input_event on this platform (two native longs)
+const EvdevTimeSize = 2 * strconv.IntSize / 8
+
+//GamepadEvent represents a single axis or button event of a gamepad
+type GamepadEvent struct {
+	Axis  bool
+	Code  uint16
+	Value int32
+}
+
+//GamepadDecoder will decode the next gamepad event from a stream of raw device bytes
+type GamepadDecoder interface {
+	Next() (GamepadEvent, error)
+}
+
+//EvdevDecoder decodes input_event structs of /dev/input/event* devices. TimeSize is the size of the timestamp
+//which depends on the platform the recording was taken on (8 on 32-bit, 16 on 64-bit systems)
+type EvdevDecoder struct {
+	Reader   io.Reader
+	TimeSize int
+	buffer   []byte
+}
+
+//Next will return the next key or abs event. All other event types (e.g. syn) will be skipped
+func (d *EvdevDecoder) Next() (GamepadEvent, error) {
+	if d.buffer == nil {
+		d.buffer = make([]byte, d.TimeSize+8)
+	}
+
+	for {
+		_, err := io.ReadFull(d.Reader, d.buffer)
+		if err != nil {
+			return GamepadEvent{}, err
+		}
+
+		data := d.buffer[d.TimeSize:]
+		typ := binary.LittleEndian.Uint16(data[0:2])
+		code := binary.LittleEndian.Uint16(data[2:4])
+		value := int32(binary.LittleEndian.Uint32(data[4:8]))
+		switch typ {
+		case evdevTypeKey:
+			return GamepadEvent{Axis: false, Code: code, Value: value}, nil
+		case evdevTypeAbs:
+			return GamepadEvent{Axis: true, Code: code, Value: value}, nil
+		}
+	}
+}
+
+//JoystickDecoder decodes js_event structs of the legacy /dev/input/js* devices
+type JoystickDecoder struct {
+	Reader io.Reader
+	buffer [jsEventSize]byte
+}
+
+//Next will return the next axis or button event. Initial state events are reported like regular events
+func (d *JoystickDecoder) Next() (GamepadEvent, error) {
+	for {
+		_, err := io.ReadFull(d.Reader, d.buffer[:])
+		if err != nil {
+			return GamepadEvent{}, err
+		}
+
+		value := int32(int16(binary.LittleEndian.Uint16(d.buffer[4:6])))
+		typ := d.buffer[6] &^ jsTypeInit
+		number := uint16(d.buffer[7])
+		switch typ {
+		case jsTypeButton:
+			return GamepadEvent{Axis: false, Code: number, Value: value}, nil
+		case jsTypeAxis:
+			return GamepadEvent{Axis: true, Code: number, Value: value}, nil
+		}
+	}
+}
+
+//GamepadAxis maps one axis of a gamepad onto a step field. Deadzone is the fraction (0-1) around the center which is ignored
+type GamepadAxis struct {
+	Code     uint16
+	Min      int32
+	Max      int32
+	Deadzone float64
+	Invert   bool
+}
+
+//GamepadMapping maps the axes and buttons of a gamepad onto the fields of a step
+type GamepadMapping struct {
+	Throttle   GamepadAxis
+	Steering   GamepadAxis
+	CameraPan  GamepadAxis
+	CameraTilt GamepadAxis
+	StopButton uint16
+}
+
+//DefaultJoystickMapping is the mapping for a common gamepad on a /dev/input/js* device
+var DefaultJoystickMapping = GamepadMapping{
+	Throttle:   GamepadAxis{Code: 1, Min: -32767, Max: 32767, Deadzone: 0.1, Invert: true},
+	Steering:   GamepadAxis{Code: 0, Min: -32767, Max: 32767, Deadzone: 0.1},
+	CameraPan:  GamepadAxis{Code: 3, Min: -32767, Max: 32767, Deadzone: 0.1},
+	CameraTilt: GamepadAxis{Code: 4, Min: -32767, Max: 32767, Deadzone: 0.1, Invert: true},
+	StopButton: 0,
+}
+
+//DefaultEvdevMapping is the mapping for a common gamepad on a /dev/input/event* device (ABS_Y, ABS_X, ABS_RX, ABS_RY and BTN_SOUTH)
+var DefaultEvdevMapping = GamepadMapping{
+	Throttle:   GamepadAxis{Code: 0x01, Min: -32768, Max: 32767, Deadzone: 0.1, Invert: true},
+	Steering:   GamepadAxis{Code: 0x00, Min: -32768, Max: 32767, Deadzone: 0.1},
+	CameraPan:  GamepadAxis{Code: 0x03, Min: -32768, Max: 32767, Deadzone: 0.1},
+	CameraTilt: GamepadAxis{Code: 0x04, Min: -32768, Max: 32767, Deadzone: 0.1, Invert: true},
+	StopButton: 0x130,
+}
+
+//LoadGamepadMapping will load a mapping from a section of an ini file. Missing keys will keep the values of the passed defaults.
+//Axis keys are prefixed by the axis name, e.g. ThrottleCode, ThrottleMin, ThrottleMax, ThrottleDeadzone and ThrottleInvert
+func LoadGamepadMapping(path string, section string, defaults GamepadMapping) (GamepadMapping, error) {
+	cfg, err := ini.Load(path)
+	if err != nil {
+		return defaults, errors.New("Could not Load ini file. Error: " + err.Error())
+	}
+
+	sec, err := cfg.GetSection(section)
+	if err != nil {
+		return defaults, fmt.Errorf("Missing Section: %v. Error: %v", section, err)
+	}
+
+	mapping := defaults
+	doLoadGamepadAxis(sec, "Throttle", &mapping.Throttle)
+	doLoadGamepadAxis(sec, "Steering", &mapping.Steering)
+	doLoadGamepadAxis(sec, "CameraPan", &mapping.CameraPan)
+	doLoadGamepadAxis(sec, "CameraTilt", &mapping.CameraTilt)
+	if sec.HasKey("StopButton") {
+		button, _ := sec.Key("StopButton").Uint()
+		mapping.StopButton = uint16(button)
+	}
+
+	return mapping, nil
+}
+
+func doLoadGamepadAxis(sec *ini.Section, name string, axis *GamepadAxis) {
+	if sec.HasKey(name + "Code") {
+		code, _ := sec.Key(name + "Code").Uint()
+		axis.Code = uint16(code)
+	}
+	if sec.HasKey(name + "Min") {
+		min, _ := sec.Key(name + "Min").Int()
+		axis.Min = int32(min)
+	}
+	if sec.HasKey(name + "Max") {
+		max, _ := sec.Key(name + "Max").Int()
+		axis.Max = int32(max)
+	}
+	if sec.HasKey(name + "Deadzone") {
+		axis.Deadzone, _ = sec.Key(name + "Deadzone").Float64()
+	}
+	if sec.HasKey(name + "Invert") {
+		axis.Invert, _ = sec.Key(name + "Invert").Bool()
+	}
+}
+
+//normalize will map a raw axis value onto -1 to 1 with the deadzone removed
+func (a GamepadAxis) normalize(value int32) float64 {
+	if a.Max <= a.Min {
+		return 0
+	}
+
+	center := (float64(a.Min) + float64(a.Max)) / 2
+	v := (float64(value) - center) / ((float64(a.Max) - float64(a.Min)) / 2)
+	v = math.Max(-1, math.Min(1, v))
+	if a.Invert {
+		v = -v
+	}
+	if math.Abs(v) <= a.Deadzone {
+		return 0
+	}
+
+	scaled := (math.Abs(v) - a.Deadzone) / (1 - a.Deadzone)
+	return math.Copysign(scaled, v)
+}
+
+//GamepadState accumulates gamepad events and translates them into steps
+type GamepadState struct {
+	Mapping    GamepadMapping
+	throttle   float64
+	steering   float64
+	pan        float64
+	tilt       float64
+	stopLocked bool
+}
+
+//Apply will apply an event to the state and return true if the resulting step changed
+func (s *GamepadState) Apply(event GamepadEvent) bool {
+	before := *s.Step()
+	m := s.Mapping
+
+	if !event.Axis {
+		if event.Code == m.StopButton && event.Value != 0 {
+			s.stopLocked = true
+		}
+		return before != *s.Step()
+	}
+
+	switch event.Code {
+	case m.Throttle.Code:
+		s.throttle = m.Throttle.normalize(event.Value)
+		if s.throttle == 0 {
+			s.stopLocked = false
+		}
+	case m.Steering.Code:
+		s.steering = m.Steering.normalize(event.Value)
+	case m.CameraPan.Code:
+		s.pan = m.CameraPan.normalize(event.Value)
+	case m.CameraTilt.Code:
+		s.tilt = m.CameraTilt.normalize(event.Value)
+	}
+
+	return before != *s.Step()
+}
+
+//Step will return the step for the current state. After the stop button was pressed the speed stays zero until the throttle was released
+func (s *GamepadState) Step() *Step {
+	speed := s.throttle * 100
+	if s.stopLocked {
+		speed = 0
+	}
+
+	return doSignedStep(speed, s.steering*100, s.pan*100, s.tilt*100)
+}
+
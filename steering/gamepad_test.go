@@ -0,0 +1,141 @@
+package steering
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+//doJoystickEvent will encode a js_event (time, value, type, number) like the kernel does
+func doJoystickEvent(typ byte, number byte, value int16) []byte {
+	event := make([]byte, jsEventSize)
+	binary.LittleEndian.PutUint32(event[0:4], 1234)
+	binary.LittleEndian.PutUint16(event[4:6], uint16(value))
+	event[6] = typ
+	event[7] = number
+	return event
+}
+
+//doEvdevEvent will encode an input_event with a 64-bit timestamp like the kernel does
+func doEvdevEvent(typ uint16, code uint16, value int32) []byte {
+	event := make([]byte, 16+8)
+	binary.LittleEndian.PutUint64(event[0:8], 1234)
+	binary.LittleEndian.PutUint16(event[16:18], typ)
+	binary.LittleEndian.PutUint16(event[18:20], code)
+	binary.LittleEndian.PutUint32(event[20:24], uint32(value))
+	return event
+}
+
+//doReplayGamepad will decode all events of a capture, apply them to a gamepad state and return every changed step
+func doReplayGamepad(t *testing.T, decoder GamepadDecoder, mapping GamepadMapping) []Step {
+	state := GamepadState{Mapping: mapping}
+	var steps []Step
+	for {
+		event, err := decoder.Next()
+		if err == io.EOF {
+			return steps
+		}
+		if err != nil {
+			t.Fatalf("Could not decode capture. Error: %v", err)
+		}
+		if state.Apply(event) {
+			steps = append(steps, *state.Step())
+		}
+	}
+}
+
+//TestGamepadReplay will replay captured event sequences of joystick and evdev devices and check the resulting steps
+func TestGamepadReplay(t *testing.T) {
+	const evdevSyn, evdevMsc = 0x00, 0x04
+	tests := []struct {
+		name    string
+		evdev   bool
+		capture [][]byte
+		steps   []Step
+	}{
+		{"joystick init state is ignored while centered", false, [][]byte{
+			doJoystickEvent(jsTypeInit|jsTypeButton, 0, 0),
+			doJoystickEvent(jsTypeInit|jsTypeAxis, 0, 0),
+			doJoystickEvent(jsTypeInit|jsTypeAxis, 1, 0),
+		}, nil},
+		{"joystick throttle forward and steer left", false, [][]byte{
+			doJoystickEvent(jsTypeAxis, 1, -32767),
+			doJoystickEvent(jsTypeAxis, 0, -16384),
+		}, []Step{
+			{Speed: 100},
+			{Speed: 100, CarMovement: HMovementLeft, CarMovementPercentage: 100 * (16384.0/32767 - 0.1) / 0.9},
+		}},
+		{"joystick deadzone", false, [][]byte{
+			doJoystickEvent(jsTypeAxis, 1, 3000),
+			doJoystickEvent(jsTypeAxis, 0, -3000),
+		}, nil},
+		{"joystick stop button locks the speed until the throttle is released", false, [][]byte{
+			doJoystickEvent(jsTypeAxis, 1, -32767),
+			doJoystickEvent(jsTypeButton, 0, 1),
+			doJoystickEvent(jsTypeButton, 0, 0),
+			doJoystickEvent(jsTypeAxis, 1, -32000),
+			doJoystickEvent(jsTypeAxis, 1, 0),
+			doJoystickEvent(jsTypeAxis, 1, 32767),
+		}, []Step{
+			{Speed: 100},
+			{},
+			{Speed: -100},
+		}},
+		{"evdev camera pan and tilt with syn events", true, [][]byte{
+			doEvdevEvent(evdevMsc, 4, 0x90001),
+			doEvdevEvent(evdevTypeAbs, 0x03, 32767),
+			doEvdevEvent(evdevSyn, 0, 0),
+			doEvdevEvent(evdevTypeAbs, 0x04, -32768),
+			doEvdevEvent(evdevSyn, 0, 0),
+			doEvdevEvent(evdevTypeAbs, 0x04, 32767),
+			doEvdevEvent(evdevSyn, 0, 0),
+		}, []Step{
+			{CameraHMovement: HMovementRight, CameraHPercentage: 100},
+			{CameraHMovement: HMovementRight, CameraHPercentage: 100, CameraVMovement: VMovementUp, CameraVPercentage: 100},
+			{CameraHMovement: HMovementRight, CameraHPercentage: 100, CameraVMovement: VMovementDown, CameraVPercentage: 100},
+		}},
+		{"evdev stop button", true, [][]byte{
+			doEvdevEvent(evdevTypeAbs, 0x01, 32767),
+			doEvdevEvent(evdevSyn, 0, 0),
+			doEvdevEvent(evdevTypeKey, 0x130, 1),
+			doEvdevEvent(evdevSyn, 0, 0),
+			doEvdevEvent(evdevTypeKey, 0x130, 0),
+		}, []Step{
+			{Speed: -100},
+			{},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			capture := bytes.NewReader(bytes.Join(test.capture, nil))
+			var decoder GamepadDecoder = &JoystickDecoder{Reader: capture}
+			mapping := DefaultJoystickMapping
+			if test.evdev {
+				decoder = &EvdevDecoder{Reader: capture, TimeSize: 16}
+				mapping = DefaultEvdevMapping
+			}
+
+			steps := doReplayGamepad(t, decoder, mapping)
+			if len(steps) != len(test.steps) {
+				t.Fatalf("Got %v steps: %+v, expected %v: %+v", len(steps), steps, len(test.steps), test.steps)
+			}
+			for i := range steps {
+				if !doStepsAlmostEqual(steps[i], test.steps[i]) {
+					t.Errorf("Step %v: %+v, expected: %+v", i, steps[i], test.steps[i])
+				}
+			}
+		})
+	}
+}
+
+//doStepsAlmostEqual will compare two steps and allow rounding errors of the percentages
+func doStepsAlmostEqual(a Step, b Step) bool {
+	near := func(x float64, y float64) bool {
+		return x-y < 1e-9 && y-x < 1e-9
+	}
+	return near(a.Speed, b.Speed) && near(a.CarMovementPercentage, b.CarMovementPercentage) &&
+		near(a.CameraHPercentage, b.CameraHPercentage) && near(a.CameraVPercentage, b.CameraVPercentage) &&
+		a.CarMovement == b.CarMovement && a.CameraHMovement == b.CameraHMovement && a.CameraVMovement == b.CameraVMovement
+}
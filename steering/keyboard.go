@@ -0,0 +1,145 @@
+package steering
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+)
+
+//Key represents a key press of the keyboard engine. Arrow keys are mapped onto special runes
+type Key rune
+
+const (
+	//KeyUp represents the arrow up key
+	KeyUp Key = -1 - iota
+	//KeyDown represents the arrow down key
+	KeyDown
+	//KeyRight represents the arrow right key
+	KeyRight
+	//KeyLeft represents the arrow left key
+	KeyLeft
+)
+
+const (
+	keyboardSpeedStep    = 10
+	keyboardSteeringStep = 20
+	keyboardCameraStep   = 10
+	keyEscape            = 0x1b
+	keyInterrupt         = 0x03
+)
+
+//KeyDecoder will decode key presses from a stream. Escape sequences of the arrow keys are translated, all other escape
+//sequences are removed. Telnet commands have to be removed by the stream (see stream.TCPSession)
+type KeyDecoder struct {
+	Reader *bufio.Reader
+}
+
+//Next will return the next key
+func (d *KeyDecoder) Next() (Key, error) {
+	for {
+		b, err := d.Reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		switch b {
+		case keyEscape:
+			key, ok, err := d.doReadEscapeSequence()
+			if err != nil {
+				return 0, err
+			}
+			if ok {
+				return key, nil
+			}
+		case 0, '\r', '\n':
+		default:
+			return Key(b), nil
+		}
+	}
+}
+
+func (d *KeyDecoder) doReadEscapeSequence() (Key, bool, error) {
+	if d.Reader.Buffered() == 0 {
+		return keyEscape, true, nil
+	}
+
+	b, err := d.Reader.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+	if b != '[' && b != 'O' {
+		return 0, false, nil
+	}
+
+	b, err = d.Reader.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+	switch b {
+	case 'A':
+		return KeyUp, true, nil
+	case 'B':
+		return KeyDown, true, nil
+	case 'C':
+		return KeyRight, true, nil
+	case 'D':
+		return KeyLeft, true, nil
+	}
+
+	return 0, false, nil
+}
+
+//KeyboardState translates key presses into steps. All values are percentages from -100 to 100, negative steering and
+//pan move to the left, positive tilt moves the camera up
+type KeyboardState struct {
+	Speed    float64
+	Steering float64
+	Pan      float64
+	Tilt     float64
+}
+
+//Apply will apply a key press to the state. It will return false if the key requests to leave the keyboard steering
+func (s *KeyboardState) Apply(key Key) bool {
+	switch key {
+	case 'w', 'W', KeyUp:
+		s.Speed = doClampPercentage(s.Speed + keyboardSpeedStep)
+	case 's', 'S', KeyDown:
+		s.Speed = doClampPercentage(s.Speed - keyboardSpeedStep)
+	case 'a', 'A', KeyLeft:
+		s.Steering = doClampPercentage(s.Steering - keyboardSteeringStep)
+	case 'd', 'D', KeyRight:
+		s.Steering = doClampPercentage(s.Steering + keyboardSteeringStep)
+	case 'i', 'I':
+		s.Tilt = doClampPercentage(s.Tilt + keyboardCameraStep)
+	case 'k', 'K':
+		s.Tilt = doClampPercentage(s.Tilt - keyboardCameraStep)
+	case 'j', 'J':
+		s.Pan = doClampPercentage(s.Pan - keyboardCameraStep)
+	case 'l', 'L':
+		s.Pan = doClampPercentage(s.Pan + keyboardCameraStep)
+	case ' ':
+		s.Speed = 0
+		s.Steering = 0
+	case 'c', 'C':
+		s.Pan = 0
+		s.Tilt = 0
+	case 'q', 'Q', keyEscape, keyInterrupt:
+		return false
+	}
+
+	return true
+}
+
+//Step will return the step for the current state
+func (s *KeyboardState) Step() *Step {
+	return doSignedStep(s.Speed, s.Steering, s.Pan, s.Tilt)
+}
+
+//String will return the status line for the current state
+func (s *KeyboardState) String() string {
+	return fmt.Sprintf("Speed: %4.0f | Steering: %4.0f | Camera Pan: %4.0f | Camera Tilt: %4.0f", s.Speed, s.Steering, s.Pan, s.Tilt)
+}
+
+func doClampPercentage(v float64) float64 {
+	return math.Max(-100, math.Min(100, v))
+}
@@ -0,0 +1,21 @@
+package steering
+
+import "testing"
+
+//TestTiltSign will check that the keyboard, the gamepad and missions move the camera into the same direction
+func TestTiltSign(t *testing.T) {
+	keyboard := KeyboardState{}
+	keyboard.Apply('i')
+	gamepad := GamepadState{Mapping: DefaultEvdevMapping}
+	gamepad.Apply(GamepadEvent{Axis: true, Code: DefaultEvdevMapping.CameraTilt.Code, Value: -32768})
+
+	for name, step := range map[string]*Step{
+		"keyboard": keyboard.Step(),
+		"gamepad":  gamepad.Step(),
+		"mission":  MissionState{Tilt: 10}.Step(),
+	} {
+		if step.CameraVMovement != VMovementUp || step.CameraVPercentage <= 0 {
+			t.Errorf("Camera of %v does not move up: %+v", name, step)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package steering
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+)
+
+const engineErrorBuffer = 16
+
+//ErrEngineRunning is returned when an engine is started which is already running
+var ErrEngineRunning = errors.New("Engine is already running")
+
+//EngineStatus is the lifecycle status of an engine
+type EngineStatus int
+
+const (
+	//EngineStopped is the status of an engine which was never started or stopped without an error
+	EngineStopped EngineStatus = iota
+	//EngineStarting is the status of an engine while Start is running
+	EngineStarting
+	//EngineRunning is the status of an engine which is steering the car
+	EngineRunning
+	//EngineFailed is the status of an engine which stopped because of an error (see Wait)
+	EngineFailed
+)
+
+func (s EngineStatus) String() string {
+	switch s {
+	case EngineStopped:
+		return "stopped"
+	case EngineStarting:
+		return "starting"
+	case EngineRunning:
+		return "running"
+	case EngineFailed:
+		return "failed"
+	}
+
+	return "unknown"
+}
+
+//Lifecycle implements Stop, Wait, Status and Errors of an engine and is meant to be embedded. Start has to call Begin
+//first and then either Abort (the engine could not be started) or Go (the engine is running)
+type Lifecycle struct {
+	mutex  sync.Mutex
+	status EngineStatus
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+	errors chan error
+}
+
+//Begin will mark the engine as starting and return the context the engine has to run in
+func (l *Lifecycle) Begin(parent context.Context) (context.Context, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.status == EngineStarting || l.status == EngineRunning {
+		return nil, ErrEngineRunning
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	l.status = EngineStarting
+	l.cancel = cancel
+	l.done = make(chan struct{})
+	l.err = nil
+	l.errors = make(chan error, engineErrorBuffer)
+	return ctx, nil
+}
+
+//Abort will mark the engine as failed to start and return the passed error
+func (l *Lifecycle) Abort(err error) error {
+	l.doFinish(err)
+	return err
+}
+
+//Go will mark the engine as running and call run in a new goroutine. The engine stops once run returned, an error
+//returned after the context was canceled does not fail the engine
+func (l *Lifecycle) Go(ctx context.Context, run func(ctx context.Context) error) {
+	l.mutex.Lock()
+	l.status = EngineRunning
+	l.mutex.Unlock()
+
+	go func() {
+		err := run(ctx)
+		if err != nil && ctx.Err() != nil {
+			log.Printf("Error while stopping engine: %v\n", err)
+			err = nil
+		}
+		l.doFinish(err)
+	}()
+}
+
+//Report will pass an error which did not stop the engine to the error channel. Errors are dropped if nobody reads them
+func (l *Lifecycle) Report(err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.status != EngineStarting && l.status != EngineRunning {
+		return
+	}
+
+	select {
+	case l.errors <- err:
+	default:
+	}
+}
+
+func (l *Lifecycle) doFinish(err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.cancel()
+	l.err = err
+	l.status = EngineStopped
+	if err != nil {
+		l.status = EngineFailed
+		select {
+		case l.errors <- err:
+		default:
+		}
+	}
+	close(l.errors)
+	close(l.done)
+}
+
+//Stop will cancel the engine and wait until it stopped
+func (l *Lifecycle) Stop() error {
+	l.mutex.Lock()
+	cancel := l.cancel
+	l.mutex.Unlock()
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	return l.Wait()
+}
+
+//Wait will block until the engine stopped and return the error it failed with
+func (l *Lifecycle) Wait() error {
+	l.mutex.Lock()
+	done := l.done
+	l.mutex.Unlock()
+	if done == nil {
+		return nil
+	}
+
+	<-done
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.err
+}
+
+//Status will return the current status of the engine
+func (l *Lifecycle) Status() EngineStatus {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.status
+}
+
+//Errors will return the channel of the errors which did not stop the engine. It is closed once the engine stopped
+func (l *Lifecycle) Errors() <-chan error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.errors
+}
@@ -0,0 +1,169 @@
+package steering
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"sdmimaye.de/smart-video-car/latency"
+)
+
+//MailboxStats contains the counters and latencies of a mailbox. Depth is the number of steps which were pending when the
+//last step was taken out of the mailbox (all but the newest were dropped). Wait is the time a step spent in the mailbox,
+//Apply the time the car needed to apply it
+type MailboxStats struct {
+	Received    uint64
+	Applied     uint64
+	Dropped     uint64
+	Failed      uint64
+	Depth       int
+	MaxDepth    int
+	LastWait    time.Duration
+	MaxWait     time.Duration
+	LastApply   time.Duration
+	MaxApply    time.Duration
+	TotalApply  time.Duration
+	TotalWait   time.Duration
+	LastApplied time.Time
+}
+
+//String will return a summary of the stats
+func (s MailboxStats) String() string {
+	avgWait, avgApply := time.Duration(0), time.Duration(0)
+	if s.Applied > 0 {
+		avgWait = s.TotalWait / time.Duration(s.Applied)
+		avgApply = s.TotalApply / time.Duration(s.Applied)
+	}
+
+	return fmt.Sprintf("Received: %v, Applied: %v, Dropped: %v, Failed: %v, Max depth: %v, Wait (avg/max): %v/%v, Apply (avg/max): %v/%v",
+		s.Received, s.Applied, s.Dropped, s.Failed, s.MaxDepth, avgWait, s.MaxWait, avgApply, s.MaxApply)
+}
+
+//Mailbox decouples the steering engines from the car. Engines put their steps into the mailbox without waiting for the car,
+//one goroutine applies only the newest pending step. Older pending steps are dropped (latest wins)
+type Mailbox struct {
+	apply    StepCallback
+	mutex    sync.Mutex
+	pending  Step
+	received time.Time
+	depth    int
+	failure  error
+	closed   bool
+	signal   chan struct{}
+	done     chan struct{}
+	stats    MailboxStats
+}
+
+//NewMailbox will create a new mailbox and start the goroutine which applies the steps
+func NewMailbox(apply StepCallback) *Mailbox {
+	m := Mailbox{apply: apply, signal: make(chan struct{}, 1), done: make(chan struct{})}
+	go m.doRun()
+
+	return &m
+}
+
+//Put will replace the pending step with a copy of the passed step. It will never wait for the car, so the step is
+//applied after Put returned. Put returns the error of a previously applied step which was not returned yet instead
+func (m *Mailbox) Put(step *Step) error {
+	m.mutex.Lock()
+	if m.closed {
+		m.mutex.Unlock()
+		return errors.New("Mailbox is already closed")
+	}
+	m.pending = *step
+	m.received = time.Now()
+	m.depth++
+	m.stats.Received++
+	if m.depth > 1 {
+		m.stats.Dropped++
+	}
+	select {
+	case m.signal <- struct{}{}:
+	default:
+	}
+	failure := m.failure
+	m.failure = nil
+	m.mutex.Unlock()
+
+	return failure
+}
+
+func (m *Mailbox) doRun() {
+	defer close(m.done)
+
+	for range m.signal {
+		m.doApplyPending()
+	}
+	m.doApplyPending()
+}
+
+func (m *Mailbox) doApplyPending() {
+	m.mutex.Lock()
+	if m.depth == 0 {
+		m.mutex.Unlock()
+		return
+	}
+	step := m.pending
+	received := m.received
+	depth := m.depth
+	m.depth = 0
+	m.mutex.Unlock()
+
+	started := time.Now()
+	err := m.apply(&step)
+	finished := time.Now()
+	latency.Queue.Observe(started.Sub(received))
+	latency.Apply.Observe(finished.Sub(started))
+
+	m.mutex.Lock()
+	m.doUpdateStats(depth, started.Sub(received), finished.Sub(started), finished, err)
+	m.mutex.Unlock()
+}
+
+func (m *Mailbox) doUpdateStats(depth int, wait time.Duration, apply time.Duration, finished time.Time, err error) {
+	s := &m.stats
+	s.Applied++
+	if err != nil {
+		s.Failed++
+		m.failure = err
+	}
+	s.Depth = depth
+	if depth > s.MaxDepth {
+		s.MaxDepth = depth
+	}
+	s.LastWait = wait
+	s.TotalWait += wait
+	if wait > s.MaxWait {
+		s.MaxWait = wait
+	}
+	s.LastApply = apply
+	s.TotalApply += apply
+	if apply > s.MaxApply {
+		s.MaxApply = apply
+	}
+	s.LastApplied = finished
+}
+
+//Stats will return the current counters and latencies of the mailbox
+func (m *Mailbox) Stats() MailboxStats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.stats
+}
+
+//Close will stop accepting steps and wait until the last pending step has been applied
+func (m *Mailbox) Close() error {
+	m.mutex.Lock()
+	if m.closed {
+		m.mutex.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.signal)
+	m.mutex.Unlock()
+
+	<-m.done
+	return nil
+}
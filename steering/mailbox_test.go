@@ -0,0 +1,48 @@
+package steering
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+//TestPutReturnsPreviousApplyError will check that an apply error is returned once by the next Put
+func TestPutReturnsPreviousApplyError(t *testing.T) {
+	failure := errors.New("motor failure")
+	mailbox := NewMailbox(func(step *Step) error {
+		if step.Speed < 0 {
+			return failure
+		}
+		return nil
+	})
+
+	err := mailbox.Put(&Step{Speed: -50})
+	if err != nil {
+		t.Fatalf("First put failed. Error: %v", err)
+	}
+	defer mailbox.Close()
+	for deadline := time.Now().Add(time.Second); mailbox.Stats().Applied == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Step was not applied")
+		}
+	}
+
+	for i, expected := range []error{failure, nil} {
+		err = mailbox.Put(&Step{Speed: 50})
+		if err != expected {
+			t.Errorf("Put %v returned: %v, expected: %v", i, err, expected)
+		}
+	}
+}
+
+//BenchmarkMailboxPut will hand steps to a mailbox whose callback does nothing
+func BenchmarkMailboxPut(b *testing.B) {
+	b.ReportAllocs()
+	mailbox := NewMailbox(func(*Step) error { return nil })
+	defer mailbox.Close()
+	step := Step{Speed: 50}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mailbox.Put(&step)
+	}
+}
@@ -0,0 +1,340 @@
+package steering
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//MissionDryRunLimit is the maximum number of instructions a dry-run will execute (e.g. for endless goto loops)
+const MissionDryRunLimit = 10000
+
+type missionOp int
+
+const (
+	missionSpeed missionOp = iota
+	missionSteer
+	missionPan
+	missionTilt
+	missionCenterCamera
+	missionStop
+	missionWait
+	missionLoop
+	missionEnd
+	missionGoto
+)
+
+type missionInstruction struct {
+	op       missionOp
+	value    float64
+	duration time.Duration
+	count    int
+	label    string
+	target   int
+	line     int
+}
+
+//Mission is a parsed mission script. A script contains one command per line, "#" starts a comment:
+//
+//	speed <-100..100> [for <duration>]
+//	steer left|right <0..100> [for <duration>] / steer center
+//	cam pan <-100..100> [for <duration>] (negative: left) / cam tilt <-100..100> [for <duration>] (negative: down) / cam center
+//	stop
+//	wait <duration>
+//	loop <count> ... end
+//	<label>:
+//	goto <label> [<count>]
+//
+//Commands with "for" will restore the previous value after the duration. A goto without count will loop forever
+type Mission struct {
+	instructions []missionInstruction
+}
+
+//LoadMission will load and parse a mission script from a file
+func LoadMission(path string) (*Mission, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open mission file: %v. Error: %v", path, err)
+	}
+	defer file.Close()
+
+	return ParseMission(file)
+}
+
+//ParseMission will parse a mission script or return an error with the line number of the invalid command
+func ParseMission(r io.Reader) (*Mission, error) {
+	m := Mission{}
+	labels := make(map[string]int)
+	var loops []int
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(strings.ToLower(text))
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) == 1 && strings.HasSuffix(fields[0], ":") {
+			label := strings.TrimSuffix(fields[0], ":")
+			if _, ok := labels[label]; ok {
+				return nil, fmt.Errorf("Duplicate label in line %v: %v", line, label)
+			}
+			labels[label] = len(m.instructions)
+			continue
+		}
+
+		in, err := doParseMissionInstruction(fields)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid mission command in line %v: %v. Error: %v", line, scanner.Text(), err)
+		}
+		in.line = line
+
+		switch in.op {
+		case missionLoop:
+			loops = append(loops, len(m.instructions))
+		case missionEnd:
+			if len(loops) == 0 {
+				return nil, fmt.Errorf("Invalid mission command in line %v: end without loop", line)
+			}
+			in.target = loops[len(loops)-1]
+			loops = loops[:len(loops)-1]
+		}
+		m.instructions = append(m.instructions, in)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Could not read mission. Error: %v", err)
+	}
+	if len(loops) > 0 {
+		return nil, fmt.Errorf("Missing end for loop in line %v", m.instructions[loops[len(loops)-1]].line)
+	}
+
+	for i := range m.instructions {
+		in := &m.instructions[i]
+		if in.op != missionGoto {
+			continue
+		}
+		target, ok := labels[in.label]
+		if !ok {
+			return nil, fmt.Errorf("Unknown label in line %v: %v", in.line, in.label)
+		}
+		in.target = target
+	}
+
+	return &m, nil
+}
+
+func doParseMissionInstruction(fields []string) (missionInstruction, error) {
+	args := fields[1:]
+	in := missionInstruction{}
+	var err error
+
+	if len(args) >= 2 && args[len(args)-2] == "for" {
+		in.duration, err = time.ParseDuration(args[len(args)-1])
+		if err != nil {
+			return in, err
+		}
+		args = args[:len(args)-2]
+	}
+
+	switch fields[0] {
+	case "speed":
+		in.op = missionSpeed
+		in.value, err = doParseMissionValue(args, 0, -100, 100)
+	case "steer":
+		in.op = missionSteer
+		if len(args) == 1 && args[0] == "center" {
+			return in, nil
+		}
+		if len(args) != 2 || (args[0] != "left" && args[0] != "right") {
+			return in, errors.New("Use steer left|right <percent> or steer center")
+		}
+		in.value, err = doParseMissionValue(args, 1, 0, 100)
+		if args[0] == "left" {
+			in.value = -in.value
+		}
+	case "cam":
+		if len(args) == 1 && args[0] == "center" {
+			in.op = missionCenterCamera
+			return in, nil
+		}
+		if len(args) != 2 || (args[0] != "pan" && args[0] != "tilt") {
+			return in, errors.New("Use cam pan|tilt <percent> or cam center")
+		}
+		in.op = missionPan
+		if args[0] == "tilt" {
+			in.op = missionTilt
+		}
+		in.value, err = doParseMissionValue(args, 1, -100, 100)
+	case "stop":
+		in.op = missionStop
+	case "wait":
+		in.op = missionWait
+		if len(args) != 1 {
+			return in, errors.New("Use wait <duration>")
+		}
+		in.duration, err = time.ParseDuration(args[0])
+	case "loop":
+		in.op = missionLoop
+		var count float64
+		count, err = doParseMissionValue(args, 0, 1, 1000000)
+		in.count = int(count)
+	case "end":
+		in.op = missionEnd
+	case "goto":
+		in.op = missionGoto
+		if len(args) < 1 || len(args) > 2 {
+			return in, errors.New("Use goto <label> [<count>]")
+		}
+		in.label = args[0]
+		if len(args) == 2 {
+			var count float64
+			count, err = doParseMissionValue(args, 1, 1, 1000000)
+			in.count = int(count)
+		}
+	default:
+		return in, fmt.Errorf("Unknown command: %v", fields[0])
+	}
+
+	return in, err
+}
+
+func doParseMissionValue(args []string, index int, min float64, max float64) (float64, error) {
+	if len(args) != index+1 {
+		return 0, errors.New("Wrong number of arguments")
+	}
+
+	value, err := strconv.ParseFloat(args[index], 64)
+	if err != nil {
+		return 0, err
+	}
+	if value < min || value > max {
+		return 0, fmt.Errorf("Value %v is out of range (%v to %v)", value, min, max)
+	}
+
+	return value, nil
+}
+
+//MissionState is the state of the car while a mission is running. All values are percentages from -100 to 100
+type MissionState struct {
+	Speed    float64
+	Steering float64
+	Pan      float64
+	Tilt     float64
+}
+
+//Step will return the step for the mission state
+func (s MissionState) Step() *Step {
+	return doSignedStep(s.Speed, s.Steering, s.Pan, s.Tilt)
+}
+
+//MissionRunner is used by Run to apply the mission states and to wait. Wait returns false if the mission has to be aborted
+type MissionRunner interface {
+	Apply(state MissionState) error
+	Wait(d time.Duration) bool
+}
+
+//Run will execute the mission with a runner. The car will be stopped at the end of the mission. At most limit instructions
+//will be executed (zero for no limit)
+func (m *Mission) Run(runner MissionRunner, limit int) error {
+	var state MissionState
+	counters := make(map[int]int)
+
+	executed := 0
+	for pc := 0; pc < len(m.instructions); pc++ {
+		executed++
+		if limit > 0 && executed > limit {
+			return fmt.Errorf("Mission exceeded the limit of %v instructions", limit)
+		}
+
+		in := m.instructions[pc]
+		previous := state
+		switch in.op {
+		case missionSpeed:
+			state.Speed = in.value
+		case missionSteer:
+			state.Steering = in.value
+		case missionPan:
+			state.Pan = in.value
+		case missionTilt:
+			state.Tilt = in.value
+		case missionCenterCamera:
+			state.Pan = 0
+			state.Tilt = 0
+		case missionStop:
+			state.Speed = 0
+			state.Steering = 0
+		case missionWait:
+			if !runner.Wait(in.duration) {
+				return runner.Apply(MissionState{})
+			}
+			continue
+		case missionLoop:
+			counters[pc] = in.count
+			continue
+		case missionEnd:
+			counters[in.target]--
+			if counters[in.target] > 0 {
+				pc = in.target
+			}
+			continue
+		case missionGoto:
+			if in.count == 0 {
+				pc = in.target - 1
+				continue
+			}
+			if _, ok := counters[pc]; !ok {
+				counters[pc] = in.count
+			}
+			if counters[pc] > 0 {
+				counters[pc]--
+				pc = in.target - 1
+			} else {
+				delete(counters, pc)
+			}
+			continue
+		}
+
+		err := runner.Apply(state)
+		if err != nil {
+			return fmt.Errorf("Could not apply mission command in line %v. Error: %v", in.line, err)
+		}
+		if in.duration > 0 {
+			if !runner.Wait(in.duration) {
+				return runner.Apply(MissionState{})
+			}
+			state = doRestoreMissionState(in.op, state, previous)
+			err = runner.Apply(state)
+			if err != nil {
+				return fmt.Errorf("Could not apply mission command in line %v. Error: %v", in.line, err)
+			}
+		}
+	}
+
+	return runner.Apply(MissionState{Pan: state.Pan, Tilt: state.Tilt})
+}
+
+func doRestoreMissionState(op missionOp, state MissionState, previous MissionState) MissionState {
+	switch op {
+	case missionSpeed:
+		state.Speed = previous.Speed
+	case missionSteer:
+		state.Steering = previous.Steering
+	case missionPan:
+		state.Pan = previous.Pan
+	case missionTilt:
+		state.Tilt = previous.Tilt
+	default:
+		state = previous
+	}
+
+	return state
+}
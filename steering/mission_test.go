@@ -0,0 +1,115 @@
+package steering
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+//missionTrace is a fake mission runner which records every applied state and every wait. Wait returns false once
+//abortAfter waits were made (zero never aborts)
+type missionTrace struct {
+	events     []string
+	waits      int
+	abortAfter int
+}
+
+func (t *missionTrace) Apply(state MissionState) error {
+	t.events = append(t.events, fmt.Sprintf("%v/%v/%v/%v", state.Speed, state.Steering, state.Pan, state.Tilt))
+	return nil
+}
+
+func (t *missionTrace) Wait(d time.Duration) bool {
+	t.waits++
+	t.events = append(t.events, "wait "+d.String())
+	return t.abortAfter == 0 || t.waits < t.abortAfter
+}
+
+//TestParseMissionErrors will check that invalid scripts are rejected
+func TestParseMissionErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{"unknown command", "fly 10", "Unknown command"},
+		{"speed out of range", "speed 101", "out of range"},
+		{"invalid duration", "speed 10 for ages", "line 1"},
+		{"steer without direction", "steer 10", "steer left|right"},
+		{"end without loop", "wait 1s\nend", "end without loop"},
+		{"missing end", "loop 2\nwait 1s", "Missing end for loop in line 1"},
+		{"unknown label", "goto start", "Unknown label in line 1"},
+		{"duplicate label", "start:\nwait 1s\nstart:\ngoto start", "Duplicate label in line 3"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseMission(strings.NewReader(test.script))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: returned: %v, expected an error containing: %v", test.name, err, test.err)
+		}
+	}
+}
+
+//TestMissionRun will run missions with a fake runner and compare the applied states and waits
+func TestMissionRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		events []string
+	}{
+		{"commands", "speed 50 # full ahead\nsteer left 30\ncam pan 20\ncam tilt -10\nwait 1s\nstop",
+			[]string{"50/0/0/0", "50/-30/0/0", "50/-30/20/0", "50/-30/20/-10", "wait 1s", "0/0/20/-10", "0/0/20/-10"}},
+		{"for restores", "speed 50\nsteer right 20 for 2s\ncam center",
+			[]string{"50/0/0/0", "50/20/0/0", "wait 2s", "50/0/0/0", "50/0/0/0", "0/0/0/0"}},
+		{"loop", "loop 3\nwait 1s\nend",
+			[]string{"wait 1s", "wait 1s", "wait 1s", "0/0/0/0"}},
+		{"nested loops", "loop 2\nspeed 10\nloop 2\nwait 1s\nend\nend",
+			[]string{"10/0/0/0", "wait 1s", "wait 1s", "10/0/0/0", "wait 1s", "wait 1s", "0/0/0/0"}},
+		{"counted goto", "start:\nwait 1s\ngoto start 2\nstop",
+			[]string{"wait 1s", "wait 1s", "wait 1s", "0/0/0/0", "0/0/0/0"}},
+	}
+
+	for _, test := range tests {
+		m, err := ParseMission(strings.NewReader(test.script))
+		if err != nil {
+			t.Errorf("%v: could not parse mission. Error: %v", test.name, err)
+			continue
+		}
+
+		var trace missionTrace
+		err = m.Run(&trace, 0)
+		if err != nil {
+			t.Errorf("%v: could not run mission. Error: %v", test.name, err)
+		}
+		if strings.Join(trace.events, ", ") != strings.Join(test.events, ", ") {
+			t.Errorf("%v: events: %v, expected: %v", test.name, trace.events, test.events)
+		}
+	}
+}
+
+//TestMissionEndlessGoto will check that an endless goto runs until it is aborted and is stopped by the dry-run limit
+func TestMissionEndlessGoto(t *testing.T) {
+	m, err := ParseMission(strings.NewReader("speed 20\nstart:\nwait 1s\ngoto start"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trace := missionTrace{abortAfter: 5}
+	err = m.Run(&trace, 0)
+	if err != nil {
+		t.Errorf("Aborted mission returned: %v", err)
+	}
+	if trace.waits != 5 || trace.events[len(trace.events)-1] != "0/0/0/0" {
+		t.Errorf("Mission was not stopped after 5 waits. Waits: %v, last event: %v", trace.waits, trace.events[len(trace.events)-1])
+	}
+
+	trace = missionTrace{}
+	err = m.Run(&trace, MissionDryRunLimit)
+	if err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Errorf("Dry-run returned: %v, expected the limit to be exceeded", err)
+	}
+	if trace.waits == 0 || trace.waits > MissionDryRunLimit {
+		t.Errorf("Dry-run waited %v times", trace.waits)
+	}
+}
@@ -0,0 +1,104 @@
+package steering
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+//RecordedStep is one line of a recording. Offset is the time since the recording was started
+type RecordedStep struct {
+	Offset time.Duration `json:"offset"`
+	Time   time.Time     `json:"time"`
+	Step   Step          `json:"step"`
+}
+
+//Recorder will record all steps passing through a step callback as JSON lines into a file
+type Recorder struct {
+	Path    string
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *json.Encoder
+	start   time.Time
+	mutex   sync.Mutex
+}
+
+//NewRecorder will create a new recording file. An existing file will be overwritten
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create recording file: %v. Error: %v", path, err)
+	}
+
+	writer := bufio.NewWriter(file)
+	return &Recorder{Path: path, file: file, writer: writer, encoder: json.NewEncoder(writer), start: time.Now()}, nil
+}
+
+//Record will append a step to the recording
+func (r *Recorder) Record(step *Step) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.file == nil {
+		return fmt.Errorf("Recording %v is already closed", r.Path)
+	}
+
+	now := time.Now()
+	err := r.encoder.Encode(RecordedStep{Offset: now.Sub(r.start), Time: now, Step: *step})
+	if err != nil {
+		return fmt.Errorf("Could not record step. Error: %v", err)
+	}
+
+	return r.writer.Flush()
+}
+
+//Close will flush and close the recording file
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	r.writer.Flush()
+	err := r.file.Close()
+	r.file = nil
+	if err != nil {
+		return fmt.Errorf("Could not close recording file: %v. Error: %v", r.Path, err)
+	}
+
+	return nil
+}
+
+//LoadRecording will read all steps of a recording
+func LoadRecording(path string) ([]RecordedStep, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open recording file: %v. Error: %v", path, err)
+	}
+	defer file.Close()
+
+	return ReadRecording(file)
+}
+
+//ReadRecording will read all steps of a recording from a reader
+func ReadRecording(r io.Reader) ([]RecordedStep, error) {
+	var steps []RecordedStep
+	decoder := json.NewDecoder(r)
+	for {
+		var step RecordedStep
+		err := decoder.Decode(&step)
+		if err == io.EOF {
+			return steps, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Could not read recorded step: %v. Error: %v", len(steps)+1, err)
+		}
+		steps = append(steps, step)
+	}
+}
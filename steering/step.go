@@ -1,12 +1,18 @@
 package steering
 
 import (
-	"bytes"
 	"encoding/binary"
 	"errors"
 	"log"
+	"math"
 )
 
+//StepSize is the size of an encoded step in bytes (without the optional sequence number)
+const StepSize = 35
+
+//Verbose enables logging of every received step. It is off by default to keep the control path cheap
+var Verbose = false
+
 //HMovement represents a horizontal movement for the car
 type HMovement int8
 
@@ -31,72 +37,128 @@ const (
 	VMovementDown VMovement = 2
 )
 
-//Step represents a movement step with a fixed speed, a direction and a camera movement
+//Step represents a movement step with a fixed speed, a direction and a camera movement.
+//Sequence is an optional number which the driver can append to a command to match the acknowledgements.
+//Timestamp is the optional send time of the driver in unix nanoseconds which follows the sequence. It is echoed in the
+//acknowledgement to measure the latency
 type Step struct {
-	Speed                 float64
-	CarMovement           HMovement
-	CarMovementPercentage float64
-	CameraHMovement       HMovement
-	CameraHPercentage     float64
-	CameraVMovement       VMovement
-	CameraVPercentage     float64
+	Speed                 float64   `json:"speed"`
+	CarMovement           HMovement `json:"carMovement"`
+	CarMovementPercentage float64   `json:"carMovementPercentage"`
+	CameraHMovement       HMovement `json:"cameraHMovement"`
+	CameraHPercentage     float64   `json:"cameraHPercentage"`
+	CameraVMovement       VMovement `json:"cameraVMovement"`
+	CameraVPercentage     float64   `json:"cameraVPercentage"`
+	Sequence              uint32    `json:"sequence,omitempty"`
+	Timestamp             int64     `json:"timestamp,omitempty"`
 }
 
+//ErrStepTooShort is returned when a command is shorter than StepSize
+var ErrStepTooShort = errors.New("Command is too short for a step")
+
 //ParseStep will parse a step or return an error
 func ParseStep(command []byte) (*Step, error) {
-	reader := bytes.NewReader(command)
-	order := binary.BigEndian
-
-	speed := float64(0)
-	err := binary.Read(reader, order, &speed)
+	var step Step
+	err := DecodeStep(command, &step)
 	if err != nil {
-		return nil, errors.New("Could not read speed from command bytes. Error: " + err.Error())
+		return nil, err
 	}
 
-	direction := int8(0)
-	err = binary.Read(reader, order, &direction)
-	if err != nil {
-		return nil, errors.New("Could not read direction from command bytes")
+	return &step, nil
+}
+
+//DecodeStep will decode a command into an existing step without allocating. The optional sequence number and timestamp
+//are read if the command is long enough, otherwise they are reset to zero
+func DecodeStep(command []byte, step *Step) error {
+	if len(command) < StepSize {
+		return ErrStepTooShort
 	}
 
-	dirpercent := float64(0)
-	err = binary.Read(reader, order, &dirpercent)
-	if err != nil {
-		return nil, errors.New("Could not read direction-percent from command bytes")
+	*step = getStep(command)
+	if len(command) >= StepSize+4 {
+		step.Sequence = binary.BigEndian.Uint32(command[StepSize:])
+	}
+	if len(command) >= StepSize+4+8 {
+		step.Timestamp = int64(binary.BigEndian.Uint64(command[StepSize+4:]))
+	}
+	if Verbose {
+		log.Printf("Speed: %v, Direction: %v, DirectionPerc: %v, CamUpDown: %v, CamUpDownPerc: %v, CamLeftRight: %v, CamLeftRightPerc: %v",
+			step.Speed, step.CarMovement, step.CarMovementPercentage, step.CameraVMovement, step.CameraVPercentage, step.CameraHMovement, step.CameraHPercentage)
 	}
 
-	camupdown := int8(0)
-	err = binary.Read(reader, order, &camupdown)
-	if err != nil {
-		return nil, errors.New("Could not read camera up/down from command bytes")
+	return nil
+}
+
+//EncodeStep will encode a step in the format read by ParseStep. The sequence number is only appended if it or the
+//timestamp is not zero, the timestamp only if it is not zero
+func EncodeStep(s *Step) []byte {
+	size := StepSize
+	if s.Sequence != 0 || s.Timestamp != 0 {
+		size += 4
+	}
+	if s.Timestamp != 0 {
+		size += 8
 	}
 
-	cudpercent := float64(0)
-	err = binary.Read(reader, order, &cudpercent)
-	if err != nil {
-		return nil, errors.New("Could not read camera up/down-percent from command bytes")
+	b := make([]byte, size)
+	putStep(b, s)
+	if size > StepSize {
+		binary.BigEndian.PutUint32(b[StepSize:], s.Sequence)
+	}
+	if s.Timestamp != 0 {
+		binary.BigEndian.PutUint64(b[StepSize+4:], uint64(s.Timestamp))
 	}
 
-	camleftright := int8(0)
-	err = binary.Read(reader, order, &camleftright)
-	if err != nil {
-		return nil, errors.New("Could not read camera left/right from command bytes")
+	return b
+}
+
+func putStep(b []byte, s *Step) {
+	order := binary.BigEndian
+	order.PutUint64(b[0:8], math.Float64bits(s.Speed))
+	b[8] = byte(s.CarMovement)
+	order.PutUint64(b[9:17], math.Float64bits(s.CarMovementPercentage))
+	b[17] = byte(s.CameraVMovement)
+	order.PutUint64(b[18:26], math.Float64bits(s.CameraVPercentage))
+	b[26] = byte(s.CameraHMovement)
+	order.PutUint64(b[27:35], math.Float64bits(s.CameraHPercentage))
+}
+
+func getStep(b []byte) Step {
+	order := binary.BigEndian
+	return Step{
+		Speed:                 math.Float64frombits(order.Uint64(b[0:8])),
+		CarMovement:           HMovement(b[8]),
+		CarMovementPercentage: math.Float64frombits(order.Uint64(b[9:17])),
+		CameraVMovement:       VMovement(b[17]),
+		CameraVPercentage:     math.Float64frombits(order.Uint64(b[18:26])),
+		CameraHMovement:       HMovement(b[26]),
+		CameraHPercentage:     math.Float64frombits(order.Uint64(b[27:35])),
 	}
+}
 
-	clrpercent := float64(0)
-	err = binary.Read(reader, order, &clrpercent)
-	if err != nil {
-		return nil, errors.New("Could not read camera left/right-percent from command bytes")
+//doSignedStep will create a step from signed percentages (-100 to 100). Negative steering and pan move to the left,
+//positive tilt moves the camera up
+func doSignedStep(speed float64, steering float64, pan float64, tilt float64) *Step {
+	step := Step{Speed: speed}
+	step.CarMovement, step.CarMovementPercentage = doHorizontalMovement(steering)
+	step.CameraHMovement, step.CameraHPercentage = doHorizontalMovement(pan)
+	switch {
+	case tilt > 0:
+		step.CameraVMovement, step.CameraVPercentage = VMovementUp, tilt
+	case tilt < 0:
+		step.CameraVMovement, step.CameraVPercentage = VMovementDown, -tilt
 	}
-	log.Printf("Speed: %v, Direction: %v, DirectionPerc: %v, CamUpDown: %v, CamUpDownPerc: %v, CamLeftRight: %v, CamLeftRightPerc: %v", speed, direction, dirpercent, camupdown, cudpercent, camleftright, clrpercent)
-
-	return &Step{
-		Speed:                 speed,
-		CarMovement:           HMovement(direction),
-		CarMovementPercentage: dirpercent,
-		CameraHMovement:       HMovement(camleftright),
-		CameraHPercentage:     clrpercent,
-		CameraVMovement:       VMovement(camupdown),
-		CameraVPercentage:     cudpercent,
-	}, nil
+
+	return &step
+}
+
+func doHorizontalMovement(v float64) (HMovement, float64) {
+	switch {
+	case v < 0:
+		return HMovementLeft, -v
+	case v > 0:
+		return HMovementRight, v
+	}
+
+	return HMovementNone, 0
 }
@@ -0,0 +1,122 @@
+package steering
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+//wireStep is the layout of an encoded step, used by the reflection based baseline
+type wireStep struct {
+	Speed            float64
+	Direction        int8
+	DirectionPercent float64
+	CameraV          int8
+	CameraVPercent   float64
+	CameraH          int8
+	CameraHPercent   float64
+	Sequence         uint32
+	Timestamp        int64
+}
+
+var benchmarkPacket = EncodeStep(&Step{Speed: 50, CarMovement: HMovementLeft, CarMovementPercentage: 30,
+	CameraHMovement: HMovementRight, CameraHPercentage: 10, CameraVMovement: VMovementUp, CameraVPercentage: 20,
+	Sequence: 1, Timestamp: 1})
+
+//BenchmarkDecodeBinaryRead will decode a step with binary.Read as the baseline of the hand written decoder
+func BenchmarkDecodeBinaryRead(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var s wireStep
+		binary.Read(bytes.NewReader(benchmarkPacket), binary.BigEndian, &s)
+	}
+}
+
+//BenchmarkParseStep will decode a step into a newly allocated step
+func BenchmarkParseStep(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ParseStep(benchmarkPacket)
+	}
+}
+
+//BenchmarkDecodeStep will decode a step into a reused step
+func BenchmarkDecodeStep(b *testing.B) {
+	b.ReportAllocs()
+	var step Step
+	for i := 0; i < b.N; i++ {
+		DecodeStep(benchmarkPacket, &step)
+	}
+}
+
+//TestStepRoundTrip will encode and decode steps with and without sequence number and timestamp
+func TestStepRoundTrip(t *testing.T) {
+	base := Step{Speed: -42.5, CarMovement: HMovementRight, CarMovementPercentage: 30, CameraHMovement: HMovementLeft,
+		CameraHPercentage: 10, CameraVMovement: VMovementDown, CameraVPercentage: 99.5}
+	tests := []struct {
+		name      string
+		sequence  uint32
+		timestamp int64
+		size      int
+	}{
+		{"plain", 0, 0, StepSize},
+		{"sequence", 7, 0, StepSize + 4},
+		{"timestamp", 0, 1234567890123, StepSize + 4 + 8},
+		{"sequence and timestamp", 7, 1234567890123, StepSize + 4 + 8},
+	}
+
+	for _, test := range tests {
+		step := base
+		step.Sequence = test.sequence
+		step.Timestamp = test.timestamp
+		b := EncodeStep(&step)
+		if len(b) != test.size {
+			t.Errorf("%v: encoded %v bytes, expected: %v", test.name, len(b), test.size)
+		}
+
+		//the decoded step is reused, so that stale sequence numbers and timestamps would show up
+		decoded := Step{Sequence: 99, Timestamp: 99}
+		err := DecodeStep(b, &decoded)
+		if err != nil {
+			t.Errorf("%v: could not decode step. Error: %v", test.name, err)
+		} else if decoded != step {
+			t.Errorf("%v: decoded %+v, expected: %+v", test.name, decoded, step)
+		}
+	}
+}
+
+//TestLegacyStepLayout will check that the 35 byte layout of clients without sequence numbers is still read and written
+func TestLegacyStepLayout(t *testing.T) {
+	legacy := struct {
+		Speed            float64
+		Direction        int8
+		DirectionPercent float64
+		CameraV          int8
+		CameraVPercent   float64
+		CameraH          int8
+		CameraHPercent   float64
+	}{50, int8(HMovementLeft), 30, int8(VMovementUp), 20, int8(HMovementRight), 10}
+	var buffer bytes.Buffer
+	binary.Write(&buffer, binary.BigEndian, &legacy)
+	if buffer.Len() != StepSize {
+		t.Fatalf("Legacy layout has %v bytes, expected: %v", buffer.Len(), StepSize)
+	}
+
+	expected := Step{Speed: 50, CarMovement: HMovementLeft, CarMovementPercentage: 30, CameraVMovement: VMovementUp,
+		CameraVPercentage: 20, CameraHMovement: HMovementRight, CameraHPercentage: 10}
+	step, err := ParseStep(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *step != expected {
+		t.Errorf("Parsed %+v, expected: %+v", *step, expected)
+	}
+	if !bytes.Equal(EncodeStep(&expected), buffer.Bytes()) {
+		t.Errorf("Encoded % x, expected: % x", EncodeStep(&expected), buffer.Bytes())
+	}
+
+	_, err = ParseStep(buffer.Bytes()[:StepSize-1])
+	if err != ErrStepTooShort {
+		t.Errorf("Parsing a short command returned: %v, expected: %v", err, ErrStepTooShort)
+	}
+}
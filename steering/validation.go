@@ -0,0 +1,111 @@
+package steering
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+//ValidationMode determines how values of a step which are out of range are handled
+type ValidationMode int
+
+const (
+	//ValidationReject will reject steps with values out of range
+	ValidationReject ValidationMode = 0
+	//ValidationClamp will clamp values out of range to the nearest valid value. Unknown movements and NaN are still rejected
+	ValidationClamp ValidationMode = 1
+)
+
+//StepError is returned for an invalid step. Code is the error code which is reported back to the driver
+type StepError struct {
+	Code  ErrorCode
+	Field string
+	Value float64
+}
+
+func (e *StepError) Error() string {
+	switch e.Code {
+	case ErrorNotANumber:
+		return fmt.Sprintf("Invalid %v: %v is not a number", e.Field, e.Value)
+	case ErrorSpeedRange:
+		return fmt.Sprintf("Invalid %v: %v. Choose a value between -100 and 100", e.Field, e.Value)
+	case ErrorPercentageRange:
+		return fmt.Sprintf("Invalid %v: %v. Choose a value between 0 and 100", e.Field, e.Value)
+	case ErrorUnknownMovement:
+		return fmt.Sprintf("Unknown %v: %v. Use either none(0), left/up(1) or right/down(2)", e.Field, e.Value)
+	}
+
+	return fmt.Sprintf("Invalid %v: %v (code %v)", e.Field, e.Value, e.Code)
+}
+
+//ErrorCodeOf will return the error code of an error. Errors which are no step errors (or overrides) are reported as ErrorMove
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return ErrorNone
+	}
+	if errors.Is(err, ErrOverridden) {
+		return ErrorOverridden
+	}
+
+	var stepError *StepError
+	if errors.As(err, &stepError) {
+		return stepError.Code
+	}
+
+	return ErrorMove
+}
+
+//Validate will check all values of the step before the step is applied. In clamp mode values out of range will be
+//corrected in place. The first invalid value will be returned as *StepError
+func (s *Step) Validate(mode ValidationMode) error {
+	err := doValidateMovement("car movement", int8(s.CarMovement))
+	if err != nil {
+		return err
+	}
+	err = doValidateMovement("camera left/right movement", int8(s.CameraHMovement))
+	if err != nil {
+		return err
+	}
+	err = doValidateMovement("camera up/down movement", int8(s.CameraVMovement))
+	if err != nil {
+		return err
+	}
+
+	err = doValidateValue("speed", &s.Speed, -100, 100, ErrorSpeedRange, mode)
+	if err != nil {
+		return err
+	}
+	err = doValidateValue("car movement percentage", &s.CarMovementPercentage, 0, 100, ErrorPercentageRange, mode)
+	if err != nil {
+		return err
+	}
+	err = doValidateValue("camera left/right percentage", &s.CameraHPercentage, 0, 100, ErrorPercentageRange, mode)
+	if err != nil {
+		return err
+	}
+
+	return doValidateValue("camera up/down percentage", &s.CameraVPercentage, 0, 100, ErrorPercentageRange, mode)
+}
+
+func doValidateMovement(field string, movement int8) error {
+	if movement < 0 || movement > 2 {
+		return &StepError{Code: ErrorUnknownMovement, Field: field, Value: float64(movement)}
+	}
+
+	return nil
+}
+
+func doValidateValue(field string, value *float64, min float64, max float64, code ErrorCode, mode ValidationMode) error {
+	if math.IsNaN(*value) || math.IsInf(*value, 0) {
+		return &StepError{Code: ErrorNotANumber, Field: field, Value: *value}
+	}
+	if *value >= min && *value <= max {
+		return nil
+	}
+	if mode != ValidationClamp {
+		return &StepError{Code: code, Field: field, Value: *value}
+	}
+
+	*value = math.Max(min, math.Min(max, *value))
+	return nil
+}
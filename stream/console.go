@@ -1,10 +1,15 @@
 package stream
 
 import (
+	"fmt"
 	"io"
 	"os"
+
+	"golang.org/x/term"
 )
 
+var consoleState *term.State
+
 //ConsoleStream represents the console for generic input/output operations
 type ConsoleStream struct {
 }
@@ -28,3 +33,27 @@ func (c ConsoleStream) Close() error {
 func (c ConsoleStream) OnConnectionEstablished(f func()) {
 	f()
 }
+
+//SetRawMode will switch the terminal of the console into raw mode or restore the previous mode
+func (c ConsoleStream) SetRawMode(raw bool) error {
+	fd := int(os.Stdin.Fd())
+	if !raw {
+		if consoleState == nil {
+			return nil
+		}
+		err := term.Restore(fd, consoleState)
+		consoleState = nil
+		return err
+	}
+
+	if consoleState != nil {
+		return nil
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("Could not switch console into raw mode. Error: %v", err)
+	}
+	consoleState = state
+
+	return nil
+}
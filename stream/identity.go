@@ -0,0 +1,126 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-ini/ini"
+)
+
+//Permission is a set of console commands a user is allowed to execute
+type Permission int
+
+const (
+	//PermissionSteer allows to steer the car
+	PermissionSteer Permission = 1 << iota
+	//PermissionCalibrate allows to (re)calibrate the car
+	PermissionCalibrate
+	//PermissionRecord allows to start and stop recordings
+	PermissionRecord
+	//PermissionAll allows every command
+	PermissionAll = PermissionSteer | PermissionCalibrate | PermissionRecord
+)
+
+var permissionNames = map[string]Permission{
+	"steer":     PermissionSteer,
+	"calibrate": PermissionCalibrate,
+	"record":    PermissionRecord,
+	"all":       PermissionAll,
+}
+
+//ParsePermission will parse a comma separated list of permissions (steer, calibrate, record or all)
+func ParsePermission(value string) (Permission, error) {
+	var p Permission
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		permission, ok := permissionNames[name]
+		if !ok {
+			return 0, fmt.Errorf("Unknown permission: %v. Use either steer, calibrate, record or all", name)
+		}
+		p |= permission
+	}
+
+	return p, nil
+}
+
+//Identity is the user of a stream and the commands the user is allowed to execute
+type Identity struct {
+	User        string
+	Permissions Permission
+}
+
+//Allowed will return true if the user has all passed permissions
+func (i Identity) Allowed(p Permission) bool {
+	return i.Permissions&p == p
+}
+
+//Anonymous is the identity of streams without authentication. It is allowed to execute every command
+var Anonymous = Identity{Permissions: PermissionAll}
+
+//AuthenticatedStream is a stream whose user is known
+type AuthenticatedStream interface {
+	Stream
+	Identity() Identity
+}
+
+//GetIdentity will return the identity of an authenticated stream or Anonymous for every other stream
+func GetIdentity(s Stream) Identity {
+	if a, ok := s.(AuthenticatedStream); ok {
+		return a.Identity()
+	}
+
+	return Anonymous
+}
+
+//Account is the identity of a user with the bcrypt hashes of its password and token. An empty hash can not be used to log in.
+//AuthorizedKeys is the authorized_keys file with the public keys the user may log in with via SSH
+type Account struct {
+	Identity
+	Password       string
+	Token          string
+	AuthorizedKeys string
+}
+
+//Users maps a login (e.g. the common name of a client certificate) to the account of a user
+type Users map[string]Account
+
+//LoadUsers will load the users from an ini file. Every section is a login with an optional user name (User, defaults
+//to the login), the permissions (Permissions, defaults to steer), the hashes of the password (Password) and the
+//token (Token) for the console login and the authorized_keys file of the user for SSH logins (AuthorizedKeys)
+func LoadUsers(path string) (Users, error) {
+	cfg, err := ini.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not load users from: %v. Error: %v", path, err)
+	}
+
+	users := Users{}
+	for _, section := range cfg.Sections() {
+		if section.Name() == ini.DEFAULT_SECTION {
+			continue
+		}
+
+		account := Account{Password: section.Key("Password").String(), Token: section.Key("Token").String(),
+			AuthorizedKeys: section.Key("AuthorizedKeys").String()}
+		account.User = section.Key("User").MustString(section.Name())
+		account.Permissions, err = ParsePermission(section.Key("Permissions").MustString("steer"))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid permissions of user: %v. Error: %v", section.Name(), err)
+		}
+		users[section.Name()] = account
+	}
+
+	return users, nil
+}
+
+//Lookup will return the identity of a login. Without any configured users every login is allowed to execute every command
+func (u Users) Lookup(login string) (Identity, bool) {
+	if u == nil {
+		return Identity{User: login, Permissions: PermissionAll}, true
+	}
+
+	account, ok := u[login]
+	return account.Identity, ok
+}
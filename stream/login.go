@@ -0,0 +1,233 @@
+package stream
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	//DefaultLoginAttempts is the number of failed logins after which a host is locked out
+	DefaultLoginAttempts = 3
+	//DefaultLoginLockout is the time a host is locked out after too many failed logins
+	DefaultLoginLockout = time.Minute
+	//loginFailureDelay is the time a client has to wait after every failed login
+	loginFailureDelay = time.Second
+)
+
+//ErrLockedOut is returned by Login if the host of the stream is locked out after too many failed logins
+var ErrLockedOut = errors.New("Too many failed logins")
+
+//unknownAccount is compared for logins without an account, so that they take as long as logins with a wrong password
+var unknownAccount = []byte("$2a$10$QO2QCmgsK/UqHKee4JFWh.u0uHLvJg7MdFfOpb9PjXfS1BybDdU72")
+
+//HashSecret will hash a password or token for the users file
+func HashSecret(secret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("Could not hash secret. Error: %v", err)
+	}
+
+	return string(hash), nil
+}
+
+//Authenticate will return the identity of a login if the secret matches its password or token
+func (u Users) Authenticate(login string, secret string) (Identity, error) {
+	account, ok := u[login]
+	if !ok {
+		bcrypt.CompareHashAndPassword(unknownAccount, []byte(secret))
+		return Identity{}, fmt.Errorf("Unknown user: %v", login)
+	}
+
+	for _, hash := range []string{account.Password, account.Token} {
+		if hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil {
+			return account.Identity, nil
+		}
+	}
+
+	return Identity{}, fmt.Errorf("Wrong password or token for user: %v", login)
+}
+
+type loginFailures struct {
+	count int
+	last  time.Time
+}
+
+//LoginLimiter counts the failed logins per host. After Attempts failed logins the host is locked out for Lockout.
+//A successful login resets the counter
+type LoginLimiter struct {
+	Attempts int
+	Lockout  time.Duration
+
+	mutex    sync.Mutex
+	failures map[string]*loginFailures
+}
+
+//NewLoginLimiter will create a new login limiter with the default attempts and lockout
+func NewLoginLimiter() *LoginLimiter {
+	return &LoginLimiter{Attempts: DefaultLoginAttempts, Lockout: DefaultLoginLockout, failures: map[string]*loginFailures{}}
+}
+
+//Locked will return how long the host is still locked out
+func (l *LoginLimiter) Locked(host string) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	f := l.failures[host]
+	if f == nil || f.count < l.Attempts {
+		return 0
+	}
+
+	remaining := time.Until(f.last.Add(l.Lockout))
+	if remaining <= 0 {
+		delete(l.failures, host)
+		return 0
+	}
+	return remaining
+}
+
+func (l *LoginLimiter) doFailed(host string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	f := l.failures[host]
+	if f == nil || time.Since(f.last) > l.Lockout {
+		f = &loginFailures{}
+		l.failures[host] = f
+	}
+	f.count++
+	f.last = time.Now()
+}
+
+func (l *LoginLimiter) doSucceeded(host string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.failures, host)
+}
+
+//loginStream is a stream after a successful login. It keeps reading from the reader of the login, so that no input
+//which was already buffered gets lost
+type loginStream struct {
+	Stream
+	reader   io.Reader
+	identity Identity
+}
+
+func (s *loginStream) GetReader() io.Reader {
+	return s.reader
+}
+
+func (s *loginStream) Identity() Identity {
+	return s.identity
+}
+
+func (s *loginStream) SetRawMode(raw bool) error {
+	if r, ok := s.Stream.(RawStream); ok {
+		return r.SetRawMode(raw)
+	}
+
+	return nil
+}
+
+//Login will ask for a login and a password or token until the user logged in or the limiter locked out the host of the
+//stream. The returned stream carries the identity of the user
+func Login(s Stream, users Users, limiter *LoginLimiter) (AuthenticatedStream, error) {
+	w := s.GetWriter()
+	reader := bufio.NewReader(s.GetReader())
+	host := doRemoteHost(s)
+
+	for attempt := 0; attempt < limiter.Attempts; attempt++ {
+		if remaining := limiter.Locked(host); remaining > 0 {
+			log.Printf("Rejected login from: %v. Host is locked out for: %v\n", host, remaining.Round(time.Second))
+			fmt.Fprintf(w, "Too many failed logins. Please try again in %v\r\n", remaining.Round(time.Second))
+			return nil, ErrLockedOut
+		}
+
+		fmt.Fprint(w, "Login: ")
+		login, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("Login from: %v aborted. Error: %v", host, err)
+		}
+		fmt.Fprint(w, "Password or token: ")
+		secret, err := doReadSecret(s, reader)
+		if err != nil {
+			return nil, fmt.Errorf("Login from: %v aborted. Error: %v", host, err)
+		}
+
+		login = strings.TrimSpace(login)
+		identity, err := users.Authenticate(login, secret)
+		if err == nil {
+			log.Printf("Login of user: %v from: %v succeeded\n", identity.User, host)
+			limiter.doSucceeded(host)
+			fmt.Fprintf(w, "Welcome %v\r\n", identity.User)
+			return &loginStream{Stream: s, reader: reader, identity: identity}, nil
+		}
+
+		log.Printf("Login from: %v failed. Error: %v\n", host, err)
+		limiter.doFailed(host)
+		time.Sleep(loginFailureDelay)
+		fmt.Fprint(w, "Login failed\r\n")
+	}
+
+	return nil, ErrLockedOut
+}
+
+//doReadSecret will read a line without echo if the stream supports the raw mode
+func doReadSecret(s Stream, reader *bufio.Reader) (string, error) {
+	raw, ok := s.(RawStream)
+	if !ok || raw.SetRawMode(true) != nil {
+		secret, err := reader.ReadString('\n')
+		return strings.TrimRight(secret, "\r\n"), err
+	}
+	defer fmt.Fprint(s.GetWriter(), "\r\n")
+	defer raw.SetRawMode(false)
+
+	var secret []byte
+	for {
+		key, err := reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+
+		switch key {
+		case '\r', '\n':
+			if reader.Buffered() > 0 {
+				if next, _ := reader.Peek(1); next[0] == '\n' || next[0] == 0 {
+					reader.ReadByte()
+				}
+			}
+			return string(secret), nil
+		case keyBackspace, keyDelete:
+			if len(secret) > 0 {
+				secret = secret[:len(secret)-1]
+			}
+		case keyInterrupt, keyEOF:
+			return "", io.EOF
+		default:
+			secret = append(secret, key)
+		}
+	}
+}
+
+//doRemoteHost will return the host of the client of a stream or local for streams without a remote address
+func doRemoteHost(s Stream) string {
+	remote, ok := s.(interface{ RemoteAddr() net.Addr })
+	if !ok {
+		return "local"
+	}
+
+	host, _, err := net.SplitHostPort(remote.RemoteAddr().String())
+	if err != nil {
+		return remote.RemoteAddr().String()
+	}
+	return host
+}
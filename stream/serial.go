@@ -0,0 +1,122 @@
+package stream
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//DefaultSerialBaudRate is the baud rate of a serial console if nothing else is configured
+const DefaultSerialBaudRate = 115200
+
+//SerialConfig contains the device and the line settings of a serial console. Parity is either N (none), E (even) or
+//O (odd)
+type SerialConfig struct {
+	Device   string
+	BaudRate int
+	DataBits int
+	Parity   byte
+	StopBits int
+}
+
+func (c SerialConfig) String() string {
+	return fmt.Sprintf("%v:%v:%v%c%v", c.Device, c.BaudRate, c.DataBits, c.Parity, c.StopBits)
+}
+
+//ParseSerialConfig will parse a serial console configuration like serial:/dev/ttyUSB0:115200:8N1. The baud rate and
+//the line settings (data bits, parity and stop bits) are optional and default to 115200 and 8N1
+func ParseSerialConfig(value string) (SerialConfig, error) {
+	config := SerialConfig{BaudRate: DefaultSerialBaudRate, DataBits: 8, Parity: 'N', StopBits: 1}
+	parts := strings.Split(strings.TrimPrefix(value, "serial:"), ":")
+	config.Device = parts[0]
+	if config.Device == "" {
+		return config, fmt.Errorf("Missing serial device in: %v. Use e.g. serial:/dev/ttyUSB0:115200", value)
+	}
+	if len(parts) > 3 {
+		return config, fmt.Errorf("Invalid serial configuration: %v. Use e.g. serial:/dev/ttyUSB0:115200:8N1", value)
+	}
+
+	if len(parts) > 1 && parts[1] != "" {
+		baud, err := strconv.Atoi(parts[1])
+		if err != nil || baud <= 0 {
+			return config, fmt.Errorf("Invalid baud rate: %v", parts[1])
+		}
+		config.BaudRate = baud
+	}
+
+	if len(parts) > 2 && parts[2] != "" {
+		line := strings.ToUpper(parts[2])
+		if len(line) != 3 || line[0] < '5' || line[0] > '8' || !strings.ContainsRune("NEO", rune(line[1])) || (line[2] != '1' && line[2] != '2') {
+			return config, fmt.Errorf("Invalid line settings: %v. Use data bits (5-8), parity (N, E or O) and stop bits (1 or 2), e.g. 8N1", parts[2])
+		}
+		config.DataBits = int(line[0] - '0')
+		config.Parity = line[1]
+		config.StopBits = int(line[2] - '0')
+	}
+
+	return config, nil
+}
+
+//SerialStream is a console on a serial device. Serial terminals send every key press on its own, so the stream
+//provides a line discipline (see Terminal). It also works on the slave of a pseudo-terminal pair
+type SerialStream struct {
+	Config   SerialConfig
+	port     io.ReadWriteCloser
+	terminal *Terminal
+
+	mutex sync.Mutex
+	err   error
+}
+
+//Read will read from the serial device and remember the error which ended the stream
+func (s *SerialStream) Read(p []byte) (int, error) {
+	n, err := s.port.Read(p)
+	if err != nil {
+		s.mutex.Lock()
+		if s.err == nil {
+			s.err = err
+		}
+		s.mutex.Unlock()
+	}
+
+	return n, err
+}
+
+func (s *SerialStream) Write(p []byte) (int, error) {
+	return s.port.Write(p)
+}
+
+//Err will return the error of the serial device which ended the stream or nil while the device works
+func (s *SerialStream) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.err
+}
+
+//GetReader will return the reading stream
+func (s *SerialStream) GetReader() io.Reader {
+	return s.terminal
+}
+
+//GetWriter will return the writing stream
+func (s *SerialStream) GetWriter() io.Writer {
+	return s.terminal
+}
+
+//Close will close the serial device
+func (s *SerialStream) Close() error {
+	return s.port.Close()
+}
+
+//OnConnectionEstablished will call f once, a serial line is connected as long as the device exists
+func (s *SerialStream) OnConnectionEstablished(f func()) {
+	f()
+}
+
+//SetRawMode will switch the line discipline into raw mode (every key press is passed through) or back into line mode
+func (s *SerialStream) SetRawMode(raw bool) error {
+	return s.terminal.SetRawMode(raw)
+}
@@ -0,0 +1,8 @@
+package stream
+
+import "errors"
+
+//OpenSerial is not supported on darwin
+func OpenSerial(config SerialConfig) (*SerialStream, error) {
+	return nil, errors.New("Serial consoles are not supported on darwin")
+}
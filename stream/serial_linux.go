@@ -0,0 +1,84 @@
+package stream
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+var serialBaudRates = map[int]uint32{
+	1200:    unix.B1200,
+	2400:    unix.B2400,
+	4800:    unix.B4800,
+	9600:    unix.B9600,
+	19200:   unix.B19200,
+	38400:   unix.B38400,
+	57600:   unix.B57600,
+	115200:  unix.B115200,
+	230400:  unix.B230400,
+	460800:  unix.B460800,
+	921600:  unix.B921600,
+	1000000: unix.B1000000,
+	1500000: unix.B1500000,
+	2000000: unix.B2000000,
+}
+
+var serialDataBits = map[int]uint32{5: unix.CS5, 6: unix.CS6, 7: unix.CS7, 8: unix.CS8}
+
+//OpenSerial will open a serial device and switch it into raw mode with the configured line settings
+func OpenSerial(config SerialConfig) (*SerialStream, error) {
+	baud, ok := serialBaudRates[config.BaudRate]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported baud rate: %v", config.BaudRate)
+	}
+
+	fd, err := unix.Open(config.Device, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open serial device: %v. Error: %v", config.Device, err)
+	}
+
+	err = doConfigureSerial(fd, config, baud)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	port := os.NewFile(uintptr(fd), config.Device)
+	s := &SerialStream{Config: config, port: port}
+	s.terminal = NewTerminal(s, true)
+	return s, nil
+}
+
+func doConfigureSerial(fd int, config SerialConfig, baud uint32) error {
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return fmt.Errorf("Could not read line settings of: %v. Error: %v", config.Device, err)
+	}
+
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB | unix.PARODD | unix.CSTOPB | unix.CBAUD
+	t.Cflag |= unix.CLOCAL | unix.CREAD | serialDataBits[config.DataBits] | baud
+	switch config.Parity {
+	case 'E':
+		t.Cflag |= unix.PARENB
+	case 'O':
+		t.Cflag |= unix.PARENB | unix.PARODD
+	}
+	if config.StopBits == 2 {
+		t.Cflag |= unix.CSTOPB
+	}
+	t.Ispeed = baud
+	t.Ospeed = baud
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+
+	err = unix.IoctlSetTermios(fd, unix.TCSETS, t)
+	if err != nil {
+		return fmt.Errorf("Could not set line settings: %v. Error: %v", config, err)
+	}
+
+	return nil
+}
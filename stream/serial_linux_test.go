@@ -0,0 +1,105 @@
+package stream
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+//doOpenPty will open a pseudo-terminal pair and return the master and the path of the slave. The test is skipped if
+//the system has no pseudo-terminals
+func doOpenPty(t *testing.T) (*os.File, string) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("No pseudo-terminals. Error: %v", err)
+	}
+	t.Cleanup(func() { master.Close() })
+
+	fd := int(master.Fd())
+	err = unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0)
+	if err != nil {
+		t.Fatalf("Could not unlock pseudo-terminal. Error: %v", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		t.Fatalf("Could not get pseudo-terminal number. Error: %v", err)
+	}
+
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+//doReadFull will read exactly n bytes or fail the test after a second
+func doReadFull(t *testing.T, r io.Reader, n int) string {
+	result := make(chan string, 1)
+	go func() {
+		buffer := make([]byte, n)
+		read, _ := io.ReadFull(r, buffer)
+		result <- string(buffer[:read])
+	}()
+
+	select {
+	case s := <-result:
+		return s
+	case <-time.After(time.Second):
+		t.Fatalf("Timeout while reading %v bytes", n)
+		return ""
+	}
+}
+
+//TestSerialConsole will open the slave of a pseudo-terminal pair as serial console and type on the master
+func TestSerialConsole(t *testing.T) {
+	master, slave := doOpenPty(t)
+	s, err := OpenSerial(SerialConfig{Device: slave, BaudRate: 9600, DataBits: 8, Parity: 'N', StopBits: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	termios, err := unix.IoctlGetTermios(int(s.port.(*os.File).Fd()), unix.TCGETS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if termios.Lflag&(unix.ICANON|unix.ECHO) != 0 || termios.Oflag&unix.OPOST != 0 {
+		t.Errorf("Serial device is not in raw mode. Lflag: %x, Oflag: %x", termios.Lflag, termios.Oflag)
+	}
+	if termios.Cflag&unix.CBAUD != unix.B9600 || termios.Cflag&unix.CSTOPB == 0 {
+		t.Errorf("Line settings were not applied. Cflag: %x", termios.Cflag)
+	}
+
+	reader := bufio.NewReader(s.GetReader())
+	fmt.Fprint(master, "stx\x7fop\r")
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "stop\n" {
+		t.Errorf("Read line: %q, expected: %q. Error: %v", line, "stop\n", err)
+	}
+	if echo := doReadFull(t, master, 10); echo != "stx\b \bop\r\n" {
+		t.Errorf("Echo: %q, expected: %q", echo, "stx\b \bop\r\n")
+	}
+
+	s.SetRawMode(true)
+	fmt.Fprint(master, "w")
+	key, err := reader.ReadByte()
+	if err != nil || key != 'w' {
+		t.Errorf("Read key: %q in raw mode, expected: 'w'. Error: %v", key, err)
+	}
+	s.SetRawMode(false)
+
+	fmt.Fprint(s.GetWriter(), "Welcome\r\n")
+	if output := doReadFull(t, master, 9); output != "Welcome\r\n" {
+		t.Errorf("Output: %q, expected: %q", output, "Welcome\r\n")
+	}
+
+	if s.Err() != nil {
+		t.Errorf("Working serial device reported error: %v", s.Err())
+	}
+	master.Close()
+	_, err = reader.ReadString('\n')
+	if err == nil || s.Err() == nil {
+		t.Errorf("Hang up was not reported. Read error: %v, stream error: %v", err, s.Err())
+	}
+}
@@ -0,0 +1,8 @@
+package stream
+
+import "errors"
+
+//OpenSerial is not supported on windows
+func OpenSerial(config SerialConfig) (*SerialStream, error) {
+	return nil, errors.New("Serial consoles are not supported on windows")
+}
@@ -0,0 +1,243 @@
+package stream
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+//sshHandshakeTimeout is the time a client has to authenticate and open its shell
+const sshHandshakeTimeout = 30 * time.Second
+
+//SSHConfig contains the host key of the server (generated if the file does not exist) and the users which may log in.
+//Every public key belongs to the user whose AuthorizedKeys file contains it and can only be used to log in as this user.
+//Without Users the keys of the AuthorizedKeys file may log in with any name and get all permissions
+type SSHConfig struct {
+	HostKey        string
+	AuthorizedKeys string
+	Users          Users
+}
+
+func (c SSHConfig) doBuild() (*ssh.ServerConfig, error) {
+	owners, err := c.doLoadKeyOwners()
+	if err != nil {
+		return nil, err
+	}
+
+	hostKey, err := doLoadOrCreateHostKey(c.HostKey)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			owner, ok := owners[string(key.Marshal())]
+			if !ok || owner != "" && owner != meta.User() {
+				log.Printf("Rejected SSH key: %v of user: %v from: %v\n", ssh.FingerprintSHA256(key), meta.User(), meta.RemoteAddr())
+				return nil, fmt.Errorf("Unknown public key for: %v", meta.User())
+			}
+			if owner == "" {
+				owner = meta.User()
+			}
+
+			return &ssh.Permissions{Extensions: map[string]string{"fingerprint": ssh.FingerprintSHA256(key), "login": owner}}, nil
+		},
+		ServerVersion: "SSH-2.0-smart-video-car",
+	}
+	config.AddHostKey(hostKey)
+
+	return config, nil
+}
+
+//doLoadKeyOwners will map every authorized public key onto the login of its user. Without users the keys belong to
+//nobody ("") and may log in with any name
+func (c SSHConfig) doLoadKeyOwners() (map[string]string, error) {
+	owners := map[string]string{}
+	if c.Users == nil {
+		authorized, err := doLoadAuthorizedKeys(c.AuthorizedKeys)
+		if err != nil {
+			return nil, err
+		}
+		for key := range authorized {
+			owners[key] = ""
+		}
+		return owners, nil
+	}
+
+	for login, account := range c.Users {
+		if account.AuthorizedKeys == "" {
+			continue
+		}
+		authorized, err := doLoadAuthorizedKeys(account.AuthorizedKeys)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid authorized keys of user: %v. Error: %v", login, err)
+		}
+		for key := range authorized {
+			if owner, ok := owners[key]; ok {
+				return nil, fmt.Errorf("Public key of user: %v is also authorized for user: %v", login, owner)
+			}
+			owners[key] = login
+		}
+	}
+	if len(owners) == 0 {
+		return nil, errors.New("None of the users has authorized keys. Please add AuthorizedKeys to the users file")
+	}
+
+	return owners, nil
+}
+
+func doLoadAuthorizedKeys(path string) (map[string]bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read authorized keys: %v. Error: %v", path, err)
+	}
+
+	authorized := map[string]bool{}
+	for len(content) > 0 {
+		key, _, _, rest, err := ssh.ParseAuthorizedKey(content)
+		if err != nil {
+			break
+		}
+		authorized[string(key.Marshal())] = true
+		content = rest
+	}
+	if len(authorized) == 0 {
+		return nil, fmt.Errorf("Authorized keys: %v contain no public key", path)
+	}
+
+	return authorized, nil
+}
+
+func doLoadOrCreateHostKey(path string) (ssh.Signer, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("Generating new SSH host key: %v\n", path)
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("Could not generate SSH host key. Error: %v", err)
+		}
+		block, err := ssh.MarshalPrivateKey(key, "smart-video-car")
+		if err != nil {
+			return nil, fmt.Errorf("Could not encode SSH host key. Error: %v", err)
+		}
+		content = pem.EncodeToMemory(block)
+		err = os.WriteFile(path, content, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("Could not store SSH host key: %v. Error: %v", path, err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("Could not read SSH host key: %v. Error: %v", path, err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(content)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse SSH host key: %v. Error: %v", path, err)
+	}
+
+	return signer, nil
+}
+
+//NewSSHServer will create a new TCPServer which accepts SSH clients. Every client gets a session as soon as it
+//requested a shell. If the client requested a pty the session provides a terminal with line editing
+func NewSSHServer(address string, policy SessionPolicy, config SSHConfig) (*TCPServer, error) {
+	sshConfig, err := config.doBuild()
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := NewTCPServer(address, policy)
+	if err != nil {
+		return nil, err
+	}
+	server.open = func(c net.Conn) (io.ReadWriteCloser, Identity, error) {
+		return doOpenShell(c, sshConfig, config.Users)
+	}
+
+	return server, nil
+}
+
+//doOpenShell will authenticate the client and wait for its shell request. Further channels are rejected
+func doOpenShell(c net.Conn, config *ssh.ServerConfig, users Users) (io.ReadWriteCloser, Identity, error) {
+	c.SetDeadline(time.Now().Add(sshHandshakeTimeout))
+	defer c.SetDeadline(time.Time{})
+
+	conn, channels, requests, err := ssh.NewServerConn(c, config)
+	if err != nil {
+		return nil, Identity{}, fmt.Errorf("SSH handshake failed. Error: %v", err)
+	}
+	go ssh.DiscardRequests(requests)
+
+	login := conn.Permissions.Extensions["login"]
+	identity, _ := users.Lookup(login)
+	log.Printf("SSH user: %v logged in with key: %v\n", login, conn.Permissions.Extensions["fingerprint"])
+	for channel := range channels {
+		if channel.ChannelType() != "session" {
+			channel.Reject(ssh.UnknownChannelType, "Only session channels are supported")
+			continue
+		}
+
+		terminal, err := doAcceptShell(conn, channel)
+		if err != nil {
+			conn.Close()
+			return nil, Identity{}, err
+		}
+		go func() {
+			for channel := range channels {
+				channel.Reject(ssh.Prohibited, "Only one session per connection is supported")
+			}
+		}()
+
+		return terminal, identity, nil
+	}
+
+	return nil, Identity{}, errors.New("SSH client disconnected before opening a session")
+}
+
+//doAcceptShell will accept a session channel and answer its requests until the shell was requested
+func doAcceptShell(conn *ssh.ServerConn, newChannel ssh.NewChannel) (*Terminal, error) {
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return nil, fmt.Errorf("Could not accept SSH session. Error: %v", err)
+	}
+
+	pty := false
+	for request := range requests {
+		switch request.Type {
+		case "pty-req":
+			pty = true
+			request.Reply(true, nil)
+		case "shell":
+			request.Reply(true, nil)
+			go func() {
+				for request := range requests {
+					if request.WantReply {
+						request.Reply(request.Type == "window-change", nil)
+					}
+				}
+			}()
+
+			terminal := NewTerminal(channel, pty)
+			terminal.closer = func() error {
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				channel.Close()
+				return conn.Close()
+			}
+			return terminal, nil
+		default:
+			if request.WantReply {
+				request.Reply(false, nil)
+			}
+		}
+	}
+
+	return nil, errors.New("SSH session closed before a shell was requested")
+}
@@ -0,0 +1,115 @@
+package stream
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+//doGenerateSSHKey will generate a client key and store its public key as authorized_keys file
+func doGenerateSSHKey(t *testing.T, path string) ssh.Signer {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path, ssh.MarshalAuthorizedKey(key), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer
+}
+
+//TestSSHKeyBelongsToUser will check that a key can only be used to log in as the user it belongs to
+func TestSSHKeyBelongsToUser(t *testing.T) {
+	dir := t.TempDir()
+	adminKey := doGenerateSSHKey(t, filepath.Join(dir, "admin.keys"))
+	driverKey := doGenerateSSHKey(t, filepath.Join(dir, "driver.keys"))
+	usersFile := filepath.Join(dir, "users.ini")
+	err := os.WriteFile(usersFile, []byte(fmt.Sprintf("[admin]\nPermissions = all\nAuthorizedKeys = %v\n\n[driver]\nPermissions = steer\nAuthorizedKeys = %v\n",
+		filepath.Join(dir, "admin.keys"), filepath.Join(dir, "driver.keys"))), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := LoadUsers(usersFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := NewSSHServer("127.0.0.1:0", SessionShared, SSHConfig{HostKey: filepath.Join(dir, "host_key"), Users: users})
+	if err != nil {
+		t.Fatal(err)
+	}
+	identities := make(chan Identity, 4)
+	go server.Serve(func(session *TCPSession) {
+		identities <- session.Identity()
+	})
+	defer server.Close()
+
+	tests := []struct {
+		login    string
+		key      ssh.Signer
+		identity *Identity
+	}{
+		{"driver", driverKey, &Identity{User: "driver", Permissions: PermissionSteer}},
+		{"admin", adminKey, &Identity{User: "admin", Permissions: PermissionAll}},
+		{"admin", driverKey, nil},
+		{"driver", adminKey, nil},
+		{"nobody", driverKey, nil},
+	}
+	for _, test := range tests {
+		client, err := ssh.Dial("tcp", server.Addr().String(), &ssh.ClientConfig{User: test.login,
+			Auth: []ssh.AuthMethod{ssh.PublicKeys(test.key)}, HostKeyCallback: ssh.InsecureIgnoreHostKey(), Timeout: time.Second})
+		if test.identity == nil {
+			if err == nil {
+				client.Close()
+				t.Errorf("Login as: %v with a key of another user succeeded", test.login)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Login as: %v failed. Error: %v", test.login, err)
+			continue
+		}
+
+		session, err := client.NewSession()
+		if err == nil {
+			err = session.Shell()
+		}
+		if err != nil {
+			t.Errorf("Could not open shell as: %v. Error: %v", test.login, err)
+		} else if identity := <-identities; identity != *test.identity {
+			t.Errorf("Login as: %v has identity: %+v, expected: %+v", test.login, identity, *test.identity)
+		}
+		client.Close()
+	}
+}
+
+//TestSSHDuplicateKey will reject a users file which authorizes the same key for two users
+func TestSSHDuplicateKey(t *testing.T) {
+	dir := t.TempDir()
+	doGenerateSSHKey(t, filepath.Join(dir, "shared.keys"))
+	users := Users{
+		"admin":  {Identity: Identity{User: "admin", Permissions: PermissionAll}, AuthorizedKeys: filepath.Join(dir, "shared.keys")},
+		"driver": {Identity: Identity{User: "driver", Permissions: PermissionSteer}, AuthorizedKeys: filepath.Join(dir, "shared.keys")},
+	}
+
+	_, err := SSHConfig{HostKey: filepath.Join(dir, "host_key"), Users: users}.doBuild()
+	if err == nil {
+		t.Error("Key authorized for two users was accepted")
+	}
+}
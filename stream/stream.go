@@ -9,3 +9,10 @@ type Stream interface {
 	OnConnectionEstablished(f func())
 	Close() error
 }
+
+//RawStream is a stream which can be switched into a raw (character) mode. In raw mode every key press is passed
+//to the reader immediately and will not be echoed
+type RawStream interface {
+	Stream
+	SetRawMode(raw bool) error
+}
@@ -1,100 +1,309 @@
 package stream
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
-	"time"
+	"strings"
+	"sync"
+
+	"sdmimaye.de/smart-video-car/network"
+)
+
+const (
+	telnetIAC  = 255
+	telnetWill = 251
+	telnetWont = 252
+	telnetEcho = 1
+	telnetSGA  = 3
+)
+
+//SessionPolicy determines how a TCP server treats a client while another client is connected
+type SessionPolicy int
+
+const (
+	//SessionExclusive allows only one client at a time. Further clients are rejected until the connected client left
+	SessionExclusive SessionPolicy = iota
+	//SessionShared allows every client to control the car
+	SessionShared
+	//SessionObserver allows one client to control the car. All other clients can only observe it
+	SessionObserver
 )
 
-var listener net.Listener
-var connection net.Conn
-var reset func()
+func (p SessionPolicy) String() string {
+	switch p {
+	case SessionExclusive:
+		return "exclusive"
+	case SessionShared:
+		return "shared"
+	case SessionObserver:
+		return "observer"
+	}
 
-//TCPStream represents a Tcp-Server which will listen on a specified port to communicate with this system
-type TCPStream struct {
+	return fmt.Sprintf("unknown(%d)", int(p))
 }
 
-func (t TCPStream) Read(p []byte) (int, error) {
-	for connection == nil {
-		time.Sleep(1 + time.Second)
+//ParseSessionPolicy will parse a session policy (exclusive, shared or observer)
+func ParseSessionPolicy(value string) (SessionPolicy, error) {
+	for _, p := range []SessionPolicy{SessionExclusive, SessionShared, SessionObserver} {
+		if strings.EqualFold(value, p.String()) {
+			return p, nil
+		}
 	}
 
-	n, err := connection.Read(p)
+	return SessionExclusive, fmt.Errorf("Unknown session policy: %v. Use either exclusive, shared or observer", value)
+}
+
+//ErrServerClosed is returned by Serve after the server was closed
+var ErrServerClosed = errors.New("TCP server closed")
+
+//TCPServer is a Tcp-Server which will listen on a specified address and give every client its own session
+type TCPServer struct {
+	Policy SessionPolicy
+
+	listener   net.Listener
+	open       func(c net.Conn) (io.ReadWriteCloser, Identity, error)
+	mutex      sync.Mutex
+	sessions   map[*TCPSession]struct{}
+	controller *TCPSession
+	closed     bool
+	handlers   sync.WaitGroup
+}
+
+//NewTCPServer will create a new TCPServer listening on an address (see network.ResolveAddress) or create an error
+func NewTCPServer(address string, policy SessionPolicy) (*TCPServer, error) {
+	listener, err := network.Listen("tcp", address)
 	if err != nil {
-		t.Close()
-		return 0, nil
+		return nil, fmt.Errorf("Could not generate TCPServer on: %v. Error: %v", address, err)
 	}
 
-	return n, nil
+	return newTCPServer(listener, policy), nil
+}
+
+func newTCPServer(listener net.Listener, policy SessionPolicy) *TCPServer {
+	return &TCPServer{Policy: policy, listener: listener, sessions: make(map[*TCPSession]struct{})}
+}
+
+//Addr will return the address the server is listening on
+func (t *TCPServer) Addr() net.Addr {
+	return t.listener.Addr()
 }
 
-func (t TCPStream) Write(p []byte) (int, error) {
-	for connection == nil {
-		time.Sleep(1 + time.Second)
+//Serve will accept clients and run the handler for every session in its own goroutine. The session is closed once
+//the handler returned. Serve blocks until the server is closed and all handlers returned. It returns ErrServerClosed
+//in that case
+func (t *TCPServer) Serve(handler func(session *TCPSession)) error {
+	for {
+		log.Print("Waiting for incomming TCP connection...\n")
+		c, err := t.listener.Accept()
+		if err != nil {
+			if t.doClosed() {
+				t.handlers.Wait()
+				return ErrServerClosed
+			}
+			return fmt.Errorf("Error while accepting TCP connection. Error: %v", err)
+		}
+
+		t.handlers.Add(1)
+		go func() {
+			defer t.handlers.Done()
+			session, err := t.doOpen(c)
+			if err != nil {
+				log.Printf("Rejected TCP connection from: %v. Reason: %v\n", c.RemoteAddr(), err)
+				c.Close()
+				return
+			}
+
+			log.Printf("TCP session opened: %v\n", session)
+			defer t.doRemove(session)
+			handler(session)
+		}()
 	}
+}
 
-	n, err := connection.Write(p)
-	if err != nil {
-		t.Close()
-		return 0, nil
+//Close will stop accepting clients, close all sessions and wait until their handlers returned
+func (t *TCPServer) Close() error {
+	t.mutex.Lock()
+	if t.closed {
+		t.mutex.Unlock()
+		return nil
+	}
+	t.closed = true
+	err := t.listener.Close()
+	for session := range t.sessions {
+		session.Close()
 	}
+	t.mutex.Unlock()
 
-	return n, nil
+	t.handlers.Wait()
+	return err
 }
 
-//NewTCPStream will create a new TCPStream or create an error
-func NewTCPStream(port int) (*TCPStream, error) {
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%v", port))
-	if err != nil {
-		return nil, fmt.Errorf("Could not generate TCPStream on Port: %v. Error: %v", port, err)
+//Sessions will return the number of connected clients
+func (t *TCPServer) Sessions() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return len(t.sessions)
+}
+
+func (t *TCPServer) doClosed() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.closed
+}
+
+func (t *TCPServer) doOpen(c net.Conn) (*TCPSession, error) {
+	var conn io.ReadWriteCloser = c
+	identity := Anonymous
+	if t.open != nil {
+		var err error
+		conn, identity, err = t.open(c)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	stream := TCPStream{}
-	go func() {
-		for {
-			log.Print("Waiting for incomming TCP connection...\n")
-			c, err := listener.Accept()
-			if err != nil {
-				log.Panicf("Error while accepting TCP connection. Error: %v\n", err)
-			}
-			go func(socket net.Conn) {
-				stream.Close()
-				connection = socket
-			}(c)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.closed || t.Policy == SessionExclusive && len(t.sessions) > 0 {
+		err := ErrServerClosed
+		if !t.closed {
+			err = errors.New("Another client is already connected. Please try again later")
+		}
+		fmt.Fprintf(conn, "%v\r\n", err)
+		conn.Close()
+		return nil, err
+	}
+
+	remote := c.RemoteAddr()
+	if name := remote.String(); name == "" || name == "@" { //unnamed unix socket
+		remote = c.LocalAddr()
+	}
+	session := &TCPSession{conn: conn, remote: remote, identity: identity}
+	if _, terminal := conn.(interface{ SetRawMode(raw bool) error }); !terminal {
+		session.telnet = &telnetFilter{}
+	}
+	if t.Policy == SessionObserver && t.controller != nil {
+		session.observer = true
+	} else if t.Policy == SessionObserver {
+		t.controller = session
+	}
+	t.sessions[session] = struct{}{}
+
+	return session, nil
+}
+
+func (t *TCPServer) doRemove(session *TCPSession) {
+	session.Close()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.sessions, session)
+	if t.controller == session {
+		t.controller = nil
+	}
+	log.Printf("TCP session closed: %v\n", session)
+}
+
+//TCPSession represents the connection of one client to the TCPServer
+type TCPSession struct {
+	conn     io.ReadWriteCloser
+	remote   net.Addr
+	telnet   *telnetFilter
+	identity Identity
+	observer bool
+	once     sync.Once
+}
+
+func (t *TCPSession) String() string {
+	role := "controller"
+	if t.observer {
+		role = "observer"
+	}
+
+	if t.identity.User != "" {
+		return fmt.Sprintf("%v@%v (%v)", t.identity.User, t.remote, role)
+	}
+
+	return fmt.Sprintf("%v (%v)", t.remote, role)
+}
+
+//Identity will return the user of the session. Sessions without client authentication are Anonymous
+func (t *TCPSession) Identity() Identity {
+	return t.identity
+}
+
+//Observer will return true if the client may only observe the car
+func (t *TCPSession) Observer() bool {
+	return t.observer
+}
+
+//RemoteAddr will return the address of the client
+func (t *TCPSession) RemoteAddr() net.Addr {
+	return t.remote
+}
+
+//Read will read from the connection. Telnet commands are removed from the data unless the connection has its own
+//terminal handling
+func (t *TCPSession) Read(p []byte) (int, error) {
+	for {
+		n, err := t.conn.Read(p)
+		if t.telnet != nil {
+			n = t.telnet.doFilter(p[:n])
+		}
+		if n > 0 || err != nil {
+			return n, err
 		}
-	}()
-	return &stream, nil
+	}
+}
+
+func (t *TCPSession) Write(p []byte) (int, error) {
+	return t.conn.Write(p)
 }
 
 //GetReader will return the reading stream
-func (t TCPStream) GetReader() io.Reader {
+func (t *TCPSession) GetReader() io.Reader {
 	return t
 }
 
 //GetWriter will return the reading stream
-func (t TCPStream) GetWriter() io.Writer {
+func (t *TCPSession) GetWriter() io.Writer {
 	return t
 }
 
-//Close will will close the tcp stream
-func (t TCPStream) Close() error {
-	if connection == nil {
-		return nil
-	}
+//Close will close the connection of the session. Pending reads and writes return with an error
+func (t *TCPSession) Close() error {
+	var err error
+	t.once.Do(func() {
+		err = t.conn.Close()
+	})
 
-	connection.Close()
-	connection = nil
-	if reset != nil {
-		reset()
-	}
-
-	return nil
+	return err
 }
 
-//OnConnectionEstablished can be set to signalize a reset in the communication
-func (t TCPStream) OnConnectionEstablished(f func()) {
-	reset = f
+//OnConnectionEstablished will call f once, because every session is exactly one connection
+func (t *TCPSession) OnConnectionEstablished(f func()) {
 	f()
 }
+
+//SetRawMode will ask the telnet client to switch into character mode (server echo and suppress go ahead) or back into line mode.
+//Clients without telnet support (e.g. netcat) will ignore this and keep sending whole lines. Connections with their
+//own terminal handling (e.g. SSH) switch their terminal instead
+func (t *TCPSession) SetRawMode(raw bool) error {
+	if terminal, ok := t.conn.(interface{ SetRawMode(raw bool) error }); ok {
+		return terminal.SetRawMode(raw)
+	}
+
+	command := byte(telnetWont)
+	if raw {
+		command = telnetWill
+	}
+
+	_, err := t.Write([]byte{telnetIAC, command, telnetEcho, telnetIAC, command, telnetSGA})
+	return err
+}
@@ -0,0 +1,59 @@
+package stream
+
+const (
+	telnetSE = 240
+	telnetSB = 250
+)
+
+//telnetFilter removes telnet commands (e.g. the answers of the client to SetRawMode) from the received data
+type telnetFilter struct {
+	state int
+}
+
+const (
+	telnetData = iota
+	telnetCommand
+	telnetOption
+	telnetSubnegotiation
+	telnetSubnegotiationIAC
+)
+
+//doFilter will remove all telnet commands from p in place and return the number of remaining data bytes
+func (f *telnetFilter) doFilter(p []byte) int {
+	n := 0
+	for _, b := range p {
+		switch f.state {
+		case telnetData:
+			if b == telnetIAC {
+				f.state = telnetCommand
+				continue
+			}
+			p[n] = b
+			n++
+		case telnetCommand:
+			f.state = telnetData
+			switch {
+			case b == telnetIAC: //escaped data byte
+				p[n] = b
+				n++
+			case b == telnetSB:
+				f.state = telnetSubnegotiation
+			case b >= telnetWill:
+				f.state = telnetOption
+			}
+		case telnetOption:
+			f.state = telnetData
+		case telnetSubnegotiation:
+			if b == telnetIAC {
+				f.state = telnetSubnegotiationIAC
+			}
+		case telnetSubnegotiationIAC:
+			f.state = telnetSubnegotiation
+			if b == telnetSE {
+				f.state = telnetData
+			}
+		}
+	}
+
+	return n
+}
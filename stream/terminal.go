@@ -0,0 +1,136 @@
+package stream
+
+import (
+	"io"
+	"sync"
+)
+
+const (
+	keyInterrupt = 0x03
+	keyEOF       = 0x04
+	keyBackspace = 0x08
+	keyEscape    = 0x1b
+	keyDelete    = 0x7f
+)
+
+//Terminal provides a line discipline for connections whose client sends every key press on its own (e.g. an SSH pty
+//or a serial terminal). In line mode the terminal echoes the input, handles backspace and passes only complete lines to
+//the reader. Ctrl-C and Ctrl-D on an empty line end the input, the next read returns io.EOF once. In raw mode every key
+//press is passed through unchanged. Without line editing the terminal passes everything through unchanged
+type Terminal struct {
+	rw      io.ReadWriteCloser
+	editing bool
+	closer  func() error
+
+	mutex   sync.Mutex
+	raw     bool
+	line    []byte
+	pending []byte
+	escape  int
+	cr      bool
+	buffer  [256]byte
+}
+
+//NewTerminal will create a new terminal on a connection. If editing is false the terminal passes everything through
+func NewTerminal(rw io.ReadWriteCloser, editing bool) *Terminal {
+	return &Terminal{rw: rw, editing: editing}
+}
+
+func (t *Terminal) Read(p []byte) (int, error) {
+	for {
+		t.mutex.Lock()
+		if len(t.pending) > 0 {
+			n := copy(p, t.pending)
+			t.pending = t.pending[n:]
+			t.mutex.Unlock()
+			return n, nil
+		}
+		cooked := t.editing && !t.raw
+		t.mutex.Unlock()
+
+		if !cooked {
+			return t.rw.Read(p)
+		}
+
+		n, err := t.rw.Read(t.buffer[:])
+		if n > 0 && t.doEdit(t.buffer[:n]) {
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+//doEdit will apply the key presses to the current line and return true if the input was ended
+func (t *Terminal) doEdit(keys []byte) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	echo := make([]byte, 0, len(keys))
+	defer func() {
+		if len(echo) > 0 {
+			t.rw.Write(echo)
+		}
+	}()
+
+	for _, key := range keys {
+		cr := t.cr
+		t.cr = key == '\r'
+		switch {
+		case t.escape == 1: //skip escape sequences like the arrow keys
+			t.escape = 0
+			if key == '[' || key == 'O' {
+				t.escape = 2
+			}
+		case t.escape == 2:
+			if key >= 0x40 && key <= 0x7e {
+				t.escape = 0
+			}
+		case key == keyEscape:
+			t.escape = 1
+		case key == '\n' && cr:
+		case key == '\r' || key == '\n':
+			echo = append(echo, '\r', '\n')
+			t.pending = append(t.pending, t.line...)
+			t.pending = append(t.pending, '\n')
+			t.line = t.line[:0]
+		case key == keyBackspace || key == keyDelete:
+			if len(t.line) > 0 {
+				t.line = t.line[:len(t.line)-1]
+				echo = append(echo, '\b', ' ', '\b')
+			}
+		case key == keyInterrupt || key == keyEOF && len(t.line) == 0:
+			echo = append(echo, '\r', '\n')
+			t.line = t.line[:0]
+			return true
+		case key >= 0x20:
+			t.line = append(t.line, key)
+			echo = append(echo, key)
+		}
+	}
+
+	return false
+}
+
+func (t *Terminal) Write(p []byte) (int, error) {
+	return t.rw.Write(p)
+}
+
+//SetRawMode will switch the terminal into raw mode (every key press is passed through) or back into line mode
+func (t *Terminal) SetRawMode(raw bool) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.raw = raw
+	return nil
+}
+
+//Close will close the connection of the terminal
+func (t *Terminal) Close() error {
+	if t.closer != nil {
+		return t.closer()
+	}
+
+	return t.rw.Close()
+}
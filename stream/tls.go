@@ -0,0 +1,94 @@
+package stream
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+//tlsHandshakeTimeout is the time a client has to finish the TLS handshake
+const tlsHandshakeTimeout = 10 * time.Second
+
+//TLSConfig contains the certificate and key of the server (PEM files). If ClientCA is set every client has to present
+//a certificate signed by it (mutual TLS). The common name of the client certificate is looked up in Users
+type TLSConfig struct {
+	Certificate string
+	Key         string
+	ClientCA    string
+	Users       Users
+}
+
+func (c TLSConfig) doBuild() (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(c.Certificate, c.Key)
+	if err != nil {
+		return nil, fmt.Errorf("Could not load server certificate: %v and key: %v. Error: %v", c.Certificate, c.Key, err)
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+	if c.ClientCA == "" {
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(c.ClientCA)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read client CA: %v. Error: %v", c.ClientCA, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("Client CA: %v contains no PEM certificate", c.ClientCA)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+
+	return config, nil
+}
+
+//NewTLSServer will create a new TCPServer which only accepts TLS connections
+func NewTLSServer(address string, policy SessionPolicy, config TLSConfig) (*TCPServer, error) {
+	tlsConfig, err := config.doBuild()
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := NewTCPServer(address, policy)
+	if err != nil {
+		return nil, err
+	}
+	server.listener = tls.NewListener(server.listener, tlsConfig)
+	server.open = func(c net.Conn) (io.ReadWriteCloser, Identity, error) {
+		identity, err := doHandshake(c.(*tls.Conn), config.Users, tlsConfig.ClientAuth == tls.RequireAndVerifyClientCert)
+		return c, identity, err
+	}
+
+	return server, nil
+}
+
+//doHandshake will finish the TLS handshake and look up the common name of the client certificate if it is required
+func doHandshake(conn *tls.Conn, users Users, certificate bool) (Identity, error) {
+	conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+	err := conn.Handshake()
+	if err != nil {
+		return Identity{}, fmt.Errorf("TLS handshake failed. Error: %v", err)
+	}
+	if !certificate {
+		return Anonymous, nil
+	}
+
+	certificates := conn.ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		return Identity{}, errors.New("Client presented no certificate")
+	}
+	name := certificates[0].Subject.CommonName
+	identity, ok := users.Lookup(name)
+	if !ok {
+		return Identity{}, fmt.Errorf("Unknown client certificate: %v", name)
+	}
+
+	return identity, nil
+}
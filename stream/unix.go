@@ -0,0 +1,129 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+//DefaultUnixSocketMode are the permissions of the socket file if nothing else is configured (owner and group)
+const DefaultUnixSocketMode os.FileMode = 0660
+
+//UnixConfig contains the path and the permissions of the socket file. Users and Groups are the names or ids of the
+//users and groups which may connect. Without any users and groups only the user of the car itself may connect
+type UnixConfig struct {
+	Path   string
+	Mode   os.FileMode
+	Users  []string
+	Groups []string
+}
+
+//peerCredentials are the user, the primary group and the supplementary groups of the process on the other end of a socket
+type peerCredentials struct {
+	uid    int
+	gid    int
+	groups []int
+}
+
+func (c UnixConfig) doResolve() (map[int]bool, map[int]bool, error) {
+	uids := map[int]bool{}
+	for _, name := range c.Users {
+		u, err := user.Lookup(name)
+		if err != nil {
+			u, err = user.LookupId(name)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("Unknown user: %v", name)
+		}
+		uid, _ := strconv.Atoi(u.Uid)
+		uids[uid] = true
+	}
+
+	gids := map[int]bool{}
+	for _, name := range c.Groups {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			g, err = user.LookupGroupId(name)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("Unknown group: %v", name)
+		}
+		gid, _ := strconv.Atoi(g.Gid)
+		gids[gid] = true
+	}
+
+	if len(uids) == 0 && len(gids) == 0 {
+		uids[os.Getuid()] = true
+	}
+	return uids, gids, nil
+}
+
+//NewUnixServer will create a new TCPServer which listens on a unix domain socket. A stale socket file is removed. Every
+//client is checked with its peer credentials and gets all permissions if its user or one of its groups is allowed
+func NewUnixServer(config UnixConfig, policy SessionPolicy) (*TCPServer, error) {
+	uids, gids, err := config.doResolve()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Lstat(config.Path)
+	if err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(config.Path)
+	}
+	listener, err := net.Listen("unix", config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not listen on unix socket: %v. Error: %v", config.Path, err)
+	}
+	mode := config.Mode
+	if mode == 0 {
+		mode = DefaultUnixSocketMode
+	}
+	err = os.Chmod(config.Path, mode)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("Could not set permissions of unix socket: %v. Error: %v", config.Path, err)
+	}
+
+	server := newTCPServer(listener, policy)
+	server.open = func(c net.Conn) (io.ReadWriteCloser, Identity, error) {
+		conn, ok := c.(*net.UnixConn)
+		if !ok {
+			return nil, Identity{}, errors.New("No unix socket connection")
+		}
+		credentials, err := doPeerCredentials(conn)
+		if err != nil {
+			return nil, Identity{}, err
+		}
+
+		name := strconv.Itoa(credentials.uid)
+		if u, err := user.LookupId(name); err == nil {
+			name = u.Username
+		}
+		if !credentials.doAllowed(uids, gids) {
+			return nil, Identity{}, fmt.Errorf("User: %v (uid: %v, gid: %v) is not allowed to connect", name, credentials.uid, credentials.gid)
+		}
+
+		log.Printf("Local user: %v (uid: %v, gid: %v) connected via unix socket\n", name, credentials.uid, credentials.gid)
+		return c, Identity{User: name, Permissions: PermissionAll}, nil
+	}
+
+	return server, nil
+}
+
+func (c peerCredentials) doAllowed(uids map[int]bool, gids map[int]bool) bool {
+	if uids[c.uid] || gids[c.gid] {
+		return true
+	}
+	for _, gid := range c.groups {
+		if gids[gid] {
+			return true
+		}
+	}
+
+	return false
+}
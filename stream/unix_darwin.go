@@ -0,0 +1,37 @@
+package stream
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+//doPeerCredentials will read the credentials of the peer process with LOCAL_PEERCRED. The first group is the primary group
+func doPeerCredentials(conn *net.UnixConn) (peerCredentials, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return peerCredentials{}, fmt.Errorf("Could not access unix socket. Error: %v", err)
+	}
+
+	var xucred *unix.Xucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		xucred, credErr = unix.GetsockoptXucred(int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	})
+	if err == nil {
+		err = credErr
+	}
+	if err != nil {
+		return peerCredentials{}, fmt.Errorf("Could not read peer credentials. Error: %v", err)
+	}
+
+	credentials := peerCredentials{uid: int(xucred.Uid), gid: -1}
+	for i := 0; i < int(xucred.Ngroups) && i < len(xucred.Groups); i++ {
+		credentials.groups = append(credentials.groups, int(xucred.Groups[i]))
+	}
+	if len(credentials.groups) > 0 {
+		credentials.gid = credentials.groups[0]
+	}
+	return credentials, nil
+}
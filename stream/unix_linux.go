@@ -0,0 +1,56 @@
+package stream
+
+import (
+	"fmt"
+	"net"
+	"os/user"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+//doPeerCredentials will read the credentials of the peer process with SO_PEERCRED. The supplementary groups are the
+//groups of the peer user
+func doPeerCredentials(conn *net.UnixConn) (peerCredentials, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return peerCredentials{}, fmt.Errorf("Could not access unix socket. Error: %v", err)
+	}
+
+	var ucred *unix.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		ucred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err == nil {
+		err = credErr
+	}
+	if err != nil {
+		return peerCredentials{}, fmt.Errorf("Could not read peer credentials. Error: %v", err)
+	}
+
+	credentials := peerCredentials{uid: int(ucred.Uid), gid: int(ucred.Gid)}
+	credentials.groups = doUserGroups(credentials.uid)
+	return credentials, nil
+}
+
+//doUserGroups will look up the groups of a user in the group database. Unlike the status of the peer process they can
+//not change between reading the credentials and the lookup
+func doUserGroups(uid int) []int {
+	u, err := user.LookupId(strconv.Itoa(uid))
+	if err != nil {
+		return nil
+	}
+	ids, err := u.GroupIds()
+	if err != nil {
+		return nil
+	}
+
+	var groups []int
+	for _, id := range ids {
+		if gid, err := strconv.Atoi(id); err == nil {
+			groups = append(groups, gid)
+		}
+	}
+	return groups
+}
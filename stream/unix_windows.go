@@ -0,0 +1,11 @@
+package stream
+
+import (
+	"errors"
+	"net"
+)
+
+//doPeerCredentials is not supported on windows, so every client is rejected
+func doPeerCredentials(conn *net.UnixConn) (peerCredentials, error) {
+	return peerCredentials{}, errors.New("Peer credentials of unix sockets are not supported on windows")
+}